@@ -0,0 +1,405 @@
+package internal
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// SocketPath is the API's Unix socket when none is given, in the project
+// root found from the working directory.
+func SocketPath() string {
+	return filepath.Join(projectRoot(), stateDir, "panopticon.sock")
+}
+
+// TokenPath holds the token the API requires when it's served beyond
+// localhost, in the project root found from the working directory.
+func TokenPath() string {
+	return filepath.Join(projectRoot(), stateDir, "api-token")
+}
+
+const (
+	// sets the API's token instead of a random one, and gives it to clients
+	tokenEnv = "PANOPTICON_API_TOKEN"
+	// carries the token for the dashboard once it's opened with ?token=
+	tokenCookie = "panopticon_token"
+)
+
+// pausedMsg reports that watching was paused or resumed.
+type pausedMsg bool
+
+// CommandStatus is a command and its latest run, as returned by the API.
+type CommandStatus struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	Cmd  string `json:"cmd"`
+	// idle, running, succeeded or failed
+	Status     string     `json:"status"`
+	Trigger    string     `json:"trigger,omitempty"`
+	Files      []string   `json:"files,omitempty"`
+	Started    *time.Time `json:"started,omitempty"`
+	DurationMs int64      `json:"duration_ms,omitempty"`
+	ExitCode   *int       `json:"exit_code,omitempty"`
+	// finished runs since panopticon started
+	Runs int `json:"runs"`
+}
+
+// WatchingStatus is whether file changes trigger runs.
+type WatchingStatus struct {
+	Paused bool `json:"paused"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// Server is the local HTTP API for listing, running and canceling commands,
+// fetching their output and metrics, streaming events and pausing watching.
+// It keeps each command's latest run from the messages it's sent.
+type Server struct {
+	m       model
+	notify  Sender
+	mux     *http.ServeMux
+	events  *EventStream
+	hub     *eventHub
+	metrics *metrics
+	// required of every request on a TCP port, if set
+	token string
+
+	mu      sync.Mutex
+	results map[int]result
+	live    map[int]*strings.Builder
+	runs    map[int]int
+}
+
+// NewServer serves the model's commands, sending pause changes to notify if
+// it isn't nil.
+func NewServer(m model, notify Sender) *Server {
+	srv := &Server{
+		m:       m,
+		notify:  notify,
+		mux:     http.NewServeMux(),
+		results: make(map[int]result),
+		live:    make(map[int]*strings.Builder),
+		runs:    make(map[int]int),
+		hub:     newEventHub(),
+		metrics: newMetrics(m.commands),
+	}
+	srv.events = newEventStream(m, srv.hub.publish)
+
+	srv.mux.HandleFunc("GET /{$}", srv.dashboard)
+	srv.mux.HandleFunc("GET /commands", srv.listCommands)
+	srv.mux.HandleFunc("GET /commands/{command}", srv.getCommand)
+	srv.mux.HandleFunc("GET /commands/{command}/output", srv.getOutput)
+	srv.mux.HandleFunc("POST /commands/{command}/run", srv.runCommand)
+	srv.mux.HandleFunc("POST /commands/{command}/cancel", srv.cancelCommand)
+	srv.mux.HandleFunc("GET /events", srv.streamEvents)
+	srv.mux.HandleFunc("GET /metrics", srv.getMetrics)
+	srv.mux.HandleFunc("GET /watching", srv.getWatching)
+	srv.mux.HandleFunc("POST /watching/pause", srv.setWatching(true))
+	srv.mux.HandleFunc("POST /watching/resume", srv.setWatching(false))
+	return srv
+}
+
+// RequireToken has requests on a TCP port carry token, so the API can be
+// served beyond localhost.
+func (srv *Server) RequireToken(token string) {
+	srv.token = token
+}
+
+func (srv *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	srv.mux.ServeHTTP(w, r)
+}
+
+// Send keeps the latest run and output of each command and streams the
+// message as an event.
+func (srv *Server) Send(msg tea.Msg) {
+	srv.events.Send(msg)
+	srv.metrics.Send(msg)
+
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case result:
+		srv.results[msg.job.ID] = msg
+		if msg.status == Pending {
+			srv.live[msg.job.ID] = &strings.Builder{}
+		} else {
+			delete(srv.live, msg.job.ID)
+			srv.runs[msg.job.ID]++
+		}
+	case outputMsg:
+		if live, ok := srv.live[msg.id]; ok && msg.of(srv.results[msg.id]) {
+			live.WriteString(msg.chunk)
+		}
+	}
+}
+
+// findCommand finds a command by its name, cmd or ID.
+func (srv *Server) findCommand(name string) (Command, bool) {
+	for _, cmd := range srv.m.commands {
+		if cmd.title() == name || cmd.Cmd == name {
+			return cmd, true
+		}
+	}
+	if id, err := strconv.Atoi(name); err == nil && id >= 0 && id < len(srv.m.commands) {
+		return srv.m.commands[id], true
+	}
+	return Command{}, false
+}
+
+// command finds the request's command, responding with 404 if there's none.
+func (srv *Server) command(w http.ResponseWriter, r *http.Request) (Command, bool) {
+	name := r.PathValue("command")
+	cmd, ok := srv.findCommand(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, apiError{fmt.Sprintf("no command %q", name)})
+	}
+	return cmd, ok
+}
+
+func (srv *Server) status(cmd Command) CommandStatus {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	status := CommandStatus{ID: cmd.ID, Name: cmd.title(), Cmd: cmd.Cmd, Status: "idle", Runs: srv.runs[cmd.ID]}
+	res, ok := srv.results[cmd.ID]
+	if !ok {
+		return status
+	}
+	status.Trigger = res.trigger.reason
+	status.Files = res.trigger.files
+	status.Started = &res.started
+	if res.status == Pending {
+		status.Status = "running"
+		return status
+	}
+	status.Status = strings.ToLower(res.status.String())
+	status.DurationMs = res.duration.Milliseconds()
+	status.ExitCode = &res.exitCode
+	return status
+}
+
+func (srv *Server) listCommands(w http.ResponseWriter, r *http.Request) {
+	statuses := make([]CommandStatus, 0, len(srv.m.commands))
+	for _, cmd := range srv.m.commands {
+		statuses = append(statuses, srv.status(cmd))
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+func (srv *Server) getCommand(w http.ResponseWriter, r *http.Request) {
+	if cmd, ok := srv.command(w, r); ok {
+		writeJSON(w, http.StatusOK, srv.status(cmd))
+	}
+}
+
+// getOutput returns the output so far of a running command, or of its
+// latest run.
+func (srv *Server) getOutput(w http.ResponseWriter, r *http.Request) {
+	cmd, ok := srv.command(w, r)
+	if !ok {
+		return
+	}
+
+	srv.mu.Lock()
+	res := srv.results[cmd.ID]
+	output := res.output
+	if live, ok := srv.live[cmd.ID]; ok {
+		output = live.String()
+	}
+	srv.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, output)
+}
+
+func (srv *Server) runCommand(w http.ResponseWriter, r *http.Request) {
+	cmd, ok := srv.command(w, r)
+	if !ok {
+		return
+	}
+	log.Println("API running command:", cmd.title())
+	executeCommand(srv.m, cmd.ID)
+	writeJSON(w, http.StatusAccepted, srv.status(cmd))
+}
+
+func (srv *Server) cancelCommand(w http.ResponseWriter, r *http.Request) {
+	cmd, ok := srv.command(w, r)
+	if !ok {
+		return
+	}
+	if !srv.m.control.cancel(cmd.ID) {
+		writeJSON(w, http.StatusConflict, apiError{fmt.Sprintf("%s isn't running", cmd.title())})
+		return
+	}
+	log.Println("API canceled command:", cmd.title())
+	writeJSON(w, http.StatusOK, srv.status(cmd))
+}
+
+func (srv *Server) getWatching(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, WatchingStatus{srv.m.control.isPaused()})
+}
+
+func (srv *Server) setWatching(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		srv.m.control.setPaused(paused)
+		if srv.notify != nil {
+			srv.notify.Send(pausedMsg(paused))
+		}
+		writeJSON(w, http.StatusOK, WatchingStatus{paused})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error writing response:", err)
+	}
+}
+
+// isTCPAddr reports whether addr is a host:port rather than a socket path.
+func isTCPAddr(addr string) bool {
+	_, port, err := net.SplitHostPort(addr)
+	return err == nil && !strings.ContainsRune(addr, os.PathSeparator) && port != ""
+}
+
+// NewToken returns $PANOPTICON_API_TOKEN, or a new random token if it isn't
+// set, and writes it to path for clients to read.
+func NewToken(path string) (string, error) {
+	token := os.Getenv(tokenEnv)
+	if token == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		token = hex.EncodeToString(b)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	// so an existing file can't keep looser permissions
+	os.Remove(path)
+	return token, os.WriteFile(path, []byte(token+"\n"), 0o600)
+}
+
+// Listen opens addr for the API: a Unix socket path, or a host:port on the
+// loopback interface unless remote is set.
+func Listen(addr string, remote bool) (net.Listener, error) {
+	if !isTCPAddr(addr) {
+		if err := os.MkdirAll(filepath.Dir(addr), 0o755); err != nil {
+			return nil, err
+		}
+		// left behind by a panopticon that didn't exit cleanly
+		if info, err := os.Stat(addr); err == nil && info.Mode()&fs.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", addr); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("%s is in use by another panopticon", addr)
+			}
+			os.Remove(addr)
+		}
+		return net.Listen("unix", addr)
+	}
+
+	if !remote && !isLoopbackHost(addr) {
+		return nil, fmt.Errorf("API address %q must be on localhost or a loopback IP without --allow-remote", addr)
+	}
+	return net.Listen("tcp", addr)
+}
+
+// guard protects the API on a TCP port, which web pages can reach as well.
+// Requests from another origin's page, which browsers send without asking
+// first, are rejected. Without a token, so are requests for any host but
+// localhost or a loopback IP, which a DNS rebinding attack would send; with
+// one, every request must carry it.
+func guard(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token == "" && !isLoopbackHost(r.Host) {
+			writeJSON(w, http.StatusForbidden, apiError{fmt.Sprintf("host %q isn't localhost", r.Host)})
+			return
+		}
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				writeJSON(w, http.StatusForbidden, apiError{fmt.Sprintf("requests from %q aren't allowed", origin)})
+				return
+			}
+		}
+		if token != "" {
+			// the dashboard's link, kept in a cookie for its requests
+			if t := r.URL.Query().Get("token"); r.URL.Path == "/" && t != "" && validToken(t, token) {
+				http.SetCookie(w, &http.Cookie{Name: tokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+				http.Redirect(w, r, "/", http.StatusSeeOther)
+				return
+			}
+			if !validToken(requestToken(r), token) {
+				writeJSON(w, http.StatusUnauthorized, apiError{"missing or wrong API token"})
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requestToken is the token in a request's Authorization header or cookie.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if c, err := r.Cookie(tokenCookie); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+func validToken(got, want string) bool {
+	return subtle.ConstantTimeCompare([]byte(got), []byte(want)) == 1
+}
+
+// isLoopbackHost reports whether host, with or without a port, is localhost
+// or a loopback IP.
+func isLoopbackHost(host string) bool {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// Serve serves the API on l until ctx is done, guarding it on a TCP port.
+func Serve(ctx context.Context, l net.Listener, srv *Server) {
+	var handler http.Handler = srv
+	if l.Addr().Network() == "tcp" {
+		handler = guard(srv, srv.token)
+	}
+	server := &http.Server{Handler: handler, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	if err := server.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Println("API server error:", err)
+	}
+}
@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	commands := []Command{
+		{ID: 0, Name: "ok", Cmd: "echo fine", Shell: "sh"},
+		{ID: 1, Name: "slow", Cmd: "echo started; sleep 10", Shell: "sh"},
+	}
+	m := model{commands: commands, theme: catppuccin, control: newRunControl(), triggerChans: []chan trigger{make(chan trigger, 1), make(chan trigger, 1)}}
+	srv := NewServer(m, nil)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	WatchForTriggers(m, srv, ctx)
+
+	do := func(method, path string) (int, string) {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		require.NoError(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		return res.StatusCode, string(body)
+	}
+	status := func(name string) CommandStatus {
+		code, body := do("GET", "/commands/"+name)
+		require.Equal(t, http.StatusOK, code)
+		var s CommandStatus
+		require.NoError(t, json.Unmarshal([]byte(body), &s))
+		return s
+	}
+
+	code, body := do("GET", "/commands")
+	require.Equal(t, http.StatusOK, code)
+	var statuses []CommandStatus
+	require.NoError(t, json.Unmarshal([]byte(body), &statuses))
+	require.Len(t, statuses, 2)
+	require.Equal(t, "idle", statuses[0].Status)
+
+	code, _ = do("GET", "/commands/nope")
+	require.Equal(t, http.StatusNotFound, code)
+
+	code, _ = do("POST", "/commands/ok/run")
+	require.Equal(t, http.StatusAccepted, code)
+	require.Eventually(t, func() bool { return status("ok").Status == "succeeded" }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 0, *status("ok").ExitCode)
+	require.Equal(t, 1, status("0").Runs)
+	_, body = do("GET", "/commands/ok/output")
+	require.Equal(t, "fine\n", body)
+
+	code, _ = do("POST", "/commands/slow/cancel")
+	require.Equal(t, http.StatusConflict, code)
+	do("POST", "/commands/slow/run")
+	require.Eventually(t, func() bool {
+		_, body := do("GET", "/commands/slow/output")
+		return body == "started\n"
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, "running", status("slow").Status)
+	code, _ = do("POST", "/commands/slow/cancel")
+	require.Equal(t, http.StatusOK, code)
+	require.Eventually(t, func() bool { return status("slow").Status == "failed" }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, -1, *status("slow").ExitCode)
+
+	code, body = do("POST", "/watching/pause")
+	require.Equal(t, http.StatusOK, code)
+	require.JSONEq(t, `{"paused":true}`, body)
+	require.True(t, m.control.isPaused())
+	do("POST", "/watching/resume")
+	_, body = do("GET", "/watching")
+	require.JSONEq(t, `{"paused":false}`, body)
+}
+
+func TestCancelQueuedRuns(t *testing.T) {
+	slow := Command{ID: 0, Name: "slow", Cmd: "echo started; sleep 10", Shell: "sh"}
+	m := model{commands: []Command{slow}, control: newRunControl()}
+	ts := httptest.NewServer(NewServer(m, nil))
+	defer ts.Close()
+
+	cancel := func() int {
+		res, err := http.Post(ts.URL+"/commands/slow/cancel", "", nil)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	started := func(r *recorder) func() bool {
+		return func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return strings.Contains(r.live.String(), "started")
+		}
+	}
+	canceled := func(r *recorder) func() bool {
+		return func() bool {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			return r.res.canceled
+		}
+	}
+
+	var first, second recorder
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		m.runTracked(slow, trigger{reason: triggerStart}, &first, context.Background())
+	}()
+	require.Eventually(t, started(&first), 5*time.Second, 10*time.Millisecond)
+	go func() {
+		defer wg.Done()
+		m.runTracked(slow, trigger{reason: triggerChange}, &second, context.Background())
+	}()
+	// queued behind the first run
+	time.Sleep(50 * time.Millisecond)
+
+	require.Equal(t, http.StatusOK, cancel())
+	require.Eventually(t, canceled(&first), 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, started(&second), 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, http.StatusOK, cancel())
+	require.Eventually(t, canceled(&second), 5*time.Second, 10*time.Millisecond)
+	wg.Wait()
+}
+
+func TestListen(t *testing.T) {
+	_, err := Listen("0.0.0.0:0", false)
+	require.Error(t, err)
+	l, err := Listen("0.0.0.0:0", true)
+	require.NoError(t, err)
+	l.Close()
+
+	l, err = Listen("127.0.0.1:0", false)
+	require.NoError(t, err)
+	l.Close()
+
+	// a socket in use by another panopticon isn't replaced
+	path := t.TempDir() + "/api.sock"
+	l, err = Listen(path, false)
+	require.NoError(t, err)
+	_, err = Listen(path, false)
+	require.ErrorContains(t, err, "in use")
+	l.Close()
+	l, err = Listen(path, false)
+	require.NoError(t, err)
+	l.Close()
+}
+
+func TestSocketPath(t *testing.T) {
+	root := t.TempDir()
+	sub := filepath.Join(root, "cmd", "server")
+	require.NoError(t, os.MkdirAll(sub, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "panopticon.toml"), nil, 0o644))
+
+	t.Chdir(sub)
+	require.Equal(t, "../../.panopticon/panopticon.sock", SocketPath())
+	t.Chdir(root)
+	require.Equal(t, ".panopticon/panopticon.sock", SocketPath())
+}
+
+func TestGuard(t *testing.T) {
+	ts := httptest.NewServer(guard(NewServer(model{control: newRunControl()}, nil), ""))
+	defer ts.Close()
+
+	do := func(host, origin string) int {
+		req, err := http.NewRequest("POST", ts.URL+"/watching/pause", nil)
+		require.NoError(t, err)
+		req.Host = host
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	addr := strings.TrimPrefix(ts.URL, "http://")
+	_, port, _ := net.SplitHostPort(addr)
+	require.Equal(t, http.StatusOK, do(addr, ""))
+	require.Equal(t, http.StatusOK, do("localhost:"+port, "http://localhost:"+port))
+	require.Equal(t, http.StatusOK, do("[::1]:"+port, ""))
+	// a page on another site, or a name rebound to 127.0.0.1
+	require.Equal(t, http.StatusForbidden, do(addr, "https://example.com"))
+	require.Equal(t, http.StatusForbidden, do(addr, "null"))
+	require.Equal(t, http.StatusForbidden, do("attacker.example:"+port, ""))
+}
+
+func TestGuardToken(t *testing.T) {
+	ts := httptest.NewServer(guard(NewServer(model{theme: dracula, control: newRunControl()}, nil), "secret"))
+	defer ts.Close()
+
+	do := func(path string, set func(*http.Request)) *http.Response {
+		req, err := http.NewRequest("GET", ts.URL+path, nil)
+		require.NoError(t, err)
+		// reached by the machine's name rather than localhost
+		req.Host = "devbox:7777"
+		set(req)
+		res, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+		res.Body.Close()
+		return res
+	}
+	none := func(*http.Request) {}
+	require.Equal(t, http.StatusUnauthorized, do("/watching", none).StatusCode)
+	require.Equal(t, http.StatusUnauthorized, do("/watching", func(r *http.Request) { r.Header.Set("Authorization", "Bearer wrong") }).StatusCode)
+	require.Equal(t, http.StatusOK, do("/watching", func(r *http.Request) { r.Header.Set("Authorization", "Bearer secret") }).StatusCode)
+
+	// the dashboard's link sets a cookie for its requests
+	res := do("/?token=secret", none)
+	require.Equal(t, http.StatusSeeOther, res.StatusCode)
+	cookies := res.Cookies()
+	require.Len(t, cookies, 1)
+	require.True(t, cookies[0].HttpOnly)
+	require.Equal(t, http.StatusOK, do("/", func(r *http.Request) { r.AddCookie(cookies[0]) }).StatusCode)
+	require.Equal(t, http.StatusUnauthorized, do("/?token=wrong", none).StatusCode)
+}
+
+func TestNewToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".panopticon", "api-token")
+	token, err := NewToken(path)
+	require.NoError(t, err)
+	require.Len(t, token, 64)
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+
+	t.Setenv(tokenEnv, "chosen")
+	token, err = NewToken(path)
+	require.NoError(t, err)
+	require.Equal(t, "chosen", token)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "chosen\n", string(data))
+}
+
+func TestDashboard(t *testing.T) {
+	ts := httptest.NewServer(NewServer(model{theme: dracula}, nil))
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "--primary: "+dracula.Primary+";")
+	require.Contains(t, string(body), `new EventSource("/events")`)
+	require.NotContains(t, string(body), "<link")
+
+	res, err = http.Get(ts.URL + "/nope")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gobwas/glob"
+)
+
+// how often logs -f checks for more output
+const followInterval = 250 * time.Millisecond
+
+// Client talks to a running panopticon's API.
+type Client struct {
+	addr string
+	base string
+	http *http.Client
+	// sent to an API served with --allow-remote
+	token string
+}
+
+// NewClient connects to the API at addr, a Unix socket path or host:port.
+func NewClient(addr string) (*Client, error) {
+	if isTCPAddr(addr) {
+		return &Client{addr: addr, base: "http://" + addr, http: &http.Client{}, token: clientToken()}, nil
+	}
+
+	if _, err := os.Stat(addr); err != nil {
+		return nil, fmt.Errorf("no running panopticon found at %s, start one with --api", addr)
+	}
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", addr)
+		},
+	}
+	return &Client{addr: addr, base: "http://panopticon", http: &http.Client{Transport: transport}}, nil
+}
+
+// clientToken is $PANOPTICON_API_TOKEN, or the token written by a running
+// panopticon if there is one.
+func clientToken() string {
+	if token := os.Getenv(tokenEnv); token != "" {
+		return token
+	}
+	data, err := os.ReadFile(TokenPath())
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func (c *Client) do(ctx context.Context, method, path string, out any) error {
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, nil)
+	if err != nil {
+		return err
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("no running panopticon found at %s: %w", c.addr, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 300 {
+		var apiErr apiError
+		if json.NewDecoder(res.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("%s %s: %s", method, path, res.Status)
+	}
+
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *string:
+		body, err := io.ReadAll(res.Body)
+		*out = string(body)
+		return err
+	default:
+		return json.NewDecoder(res.Body).Decode(out)
+	}
+}
+
+func commandPath(name string) string {
+	return "/commands/" + url.PathEscape(name)
+}
+
+// Commands returns every command and its latest run.
+func (c *Client) Commands(ctx context.Context) ([]CommandStatus, error) {
+	var statuses []CommandStatus
+	return statuses, c.do(ctx, http.MethodGet, "/commands", &statuses)
+}
+
+// Command returns a command by name, cmd or ID.
+func (c *Client) Command(ctx context.Context, name string) (CommandStatus, error) {
+	var status CommandStatus
+	return status, c.do(ctx, http.MethodGet, commandPath(name), &status)
+}
+
+// Output returns a command's output so far, or that of its latest run.
+func (c *Client) Output(ctx context.Context, name string) (string, error) {
+	var output string
+	return output, c.do(ctx, http.MethodGet, commandPath(name)+"/output", &output)
+}
+
+// Run runs a command.
+func (c *Client) Run(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, commandPath(name)+"/run", nil)
+}
+
+// Watching reports whether watching is paused.
+func (c *Client) Watching(ctx context.Context) (WatchingStatus, error) {
+	var status WatchingStatus
+	return status, c.do(ctx, http.MethodGet, "/watching", &status)
+}
+
+// Trigger runs every command whose name or cmd matches one of patterns.
+func Trigger(ctx context.Context, c *Client, out io.Writer, patterns []string) error {
+	var globs []glob.Glob
+	for _, pattern := range patterns {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		globs = append(globs, g)
+	}
+
+	statuses, err := c.Commands(ctx)
+	if err != nil {
+		return err
+	}
+	var triggered int
+	for _, s := range statuses {
+		for _, g := range globs {
+			if g.Match(s.Name) || g.Match(s.Cmd) {
+				if err := c.Run(ctx, fmt.Sprint(s.ID)); err != nil {
+					return err
+				}
+				fmt.Fprintln(out, "Triggered", s.Name)
+				triggered++
+				break
+			}
+		}
+	}
+	if triggered == 0 {
+		return fmt.Errorf("no commands match %s", strings.Join(patterns, ", "))
+	}
+	return nil
+}
+
+// PrintStatus prints a table of each command's current state.
+func PrintStatus(ctx context.Context, c *Client, out io.Writer) error {
+	statuses, err := c.Commands(ctx)
+	if err != nil {
+		return err
+	}
+	watching, err := c.Watching(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tCOMMAND\tSTATUS\tDURATION\tEXIT\tSTARTED\tTRIGGER")
+	for _, s := range statuses {
+		var emoji, duration, exitCode, started string
+		switch s.Status {
+		case "succeeded":
+			emoji = getEmoji(Succeeded)
+		case "failed":
+			emoji = getEmoji(Failed)
+		case "running":
+			emoji = getEmoji(Pending)
+		}
+		if s.ExitCode != nil {
+			duration = (time.Duration(s.DurationMs) * time.Millisecond).String()
+			exitCode = fmt.Sprint(*s.ExitCode)
+		}
+		if s.Started != nil {
+			started = s.Started.Local().Format(time.TimeOnly)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", emoji, s.Name, s.Status, duration, exitCode, started, trigger{s.Trigger, s.Files})
+	}
+	w.Flush()
+
+	if watching.Paused {
+		fmt.Fprintln(out, "\nWatching is paused")
+	}
+	return nil
+}
+
+// Logs prints a command's output, then with follow keeps printing output as
+// it arrives, run after run, until ctx is done.
+func Logs(ctx context.Context, c *Client, out io.Writer, name string, follow bool) error {
+	status, err := c.Command(ctx, name)
+	if err != nil {
+		return err
+	}
+	output, err := c.Output(ctx, name)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, output)
+	if !follow {
+		return nil
+	}
+
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		latest, err := c.Command(ctx, name)
+		if err != nil {
+			return ignoreCanceled(ctx, err)
+		}
+		if !sameRun(status, latest) {
+			output = ""
+			fmt.Fprintf(out, "\n--- %s started (%s) ---\n", latest.Name, trigger{latest.Trigger, latest.Files})
+		}
+		status = latest
+
+		next, err := c.Output(ctx, name)
+		if err != nil {
+			return ignoreCanceled(ctx, err)
+		}
+		// a finished run's output can differ from what streamed, such as
+		// stderr being put first, so only print what's new
+		if strings.HasPrefix(next, output) {
+			fmt.Fprint(out, next[len(output):])
+		}
+		output = next
+	}
+}
+
+func sameRun(a, b CommandStatus) bool {
+	if a.Started == nil || b.Started == nil {
+		return a.Started == b.Started
+	}
+	return a.Started.Equal(*b.Started)
+}
+
+func ignoreCanceled(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return nil
+	}
+	return err
+}
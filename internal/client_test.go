@@ -0,0 +1,73 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient(t *testing.T) {
+	commands := []Command{
+		{ID: 0, Name: "lint", Cmd: "echo linted", Shell: "sh"},
+		{ID: 1, Name: "test-unit", Cmd: "echo unit; exit 1", Shell: "sh"},
+		{ID: 2, Name: "test-e2e", Cmd: "true", Shell: "sh"},
+	}
+	m := model{commands: commands, theme: catppuccin, control: newRunControl(), triggerChans: []chan trigger{make(chan trigger, 1), make(chan trigger, 1), make(chan trigger, 1)}}
+	srv := NewServer(m, nil)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	WatchForTriggers(m, srv, ctx)
+
+	c, err := NewClient(strings.TrimPrefix(ts.URL, "http://"))
+	require.NoError(t, err)
+
+	var out bytes.Buffer
+	require.NoError(t, Trigger(ctx, c, &out, []string{"test-u*", "lint"}))
+	require.Equal(t, "Triggered lint\nTriggered test-unit\n", out.String())
+	require.ErrorContains(t, Trigger(ctx, c, &out, []string{"nope"}), "no commands match nope")
+
+	require.Eventually(t, func() bool {
+		s, err := c.Command(ctx, "test-unit")
+		return err == nil && s.Status == "failed"
+	}, 5*time.Second, 10*time.Millisecond)
+
+	out.Reset()
+	require.NoError(t, PrintStatus(ctx, c, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Regexp(t, `^❌\s+test-unit\s+failed\s+\S+\s+1\s+\S+\s+manual$`, lines[2])
+	require.Regexp(t, `^test-e2e\s+idle$`, strings.TrimSpace(lines[3]))
+
+	out.Reset()
+	require.NoError(t, Logs(ctx, c, &out, "test-unit", false))
+	require.Contains(t, out.String(), "unit")
+	require.ErrorContains(t, Logs(ctx, c, &out, "nope", false), `no command "nope"`)
+
+	_, err = NewClient(t.TempDir() + "/panopticon.sock")
+	require.ErrorContains(t, err, "no running panopticon")
+}
+
+func TestClientToken(t *testing.T) {
+	ts := httptest.NewServer(guard(NewServer(model{control: newRunControl()}, nil), "secret"))
+	defer ts.Close()
+	addr := strings.TrimPrefix(ts.URL, "http://")
+
+	c, err := NewClient(addr)
+	require.NoError(t, err)
+	_, err = c.Watching(context.Background())
+	require.ErrorContains(t, err, "missing or wrong API token")
+
+	t.Setenv(tokenEnv, "secret")
+	c, err = NewClient(addr)
+	require.NoError(t, err)
+	_, err = c.Watching(context.Background())
+	require.NoError(t, err)
+}
@@ -3,32 +3,194 @@ package internal
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"os/exec"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
 
-func runProcess(command Command, p *tea.Program, ctx context.Context) {
-	p.Send(result{1, Pending, command, ""})
+// Sender receives results and output from running commands, such as the
+// TUI's *tea.Program or a Headless printer.
+type Sender interface {
+	Send(msg tea.Msg)
+}
+
+// broadcast sends each message to every sender in turn.
+type broadcast []Sender
+
+func (b broadcast) Send(msg tea.Msg) {
+	for _, s := range b {
+		s.Send(msg)
+	}
+}
+
+// NewBroadcast returns a Sender passing messages to each of to and to the
+// model's problems files, reports, notifier and hooks.
+func NewBroadcast(m model, to ...Sender) Sender {
+	b := broadcast(to)
+	if m.problems != nil {
+		b = append(b, m.problems)
+	}
+	if m.reports != nil {
+		b = append(b, m.reports)
+	}
+	if m.notifier != nil {
+		b = append(b, m.notifier)
+	}
+	if m.hooks != nil {
+		b = append(b, m.hooks)
+	}
+	return b
+}
+
+// trigger is why a command ran: on start, by hand, or after files changed.
+type trigger struct {
+	reason string
+	files  []string
+}
+
+const (
+	triggerStart  = "start"
+	triggerManual = "manual"
+	triggerChange = "change"
+)
+
+func (t trigger) String() string {
+	if len(t.files) == 0 {
+		return t.reason
+	}
+	const shown = 3
+	files := t.files
+	if len(files) > shown {
+		files = append(files[:shown:shown], fmt.Sprintf("%d more", len(t.files)-shown))
+	}
+	return t.reason + ": " + strings.Join(files, ", ")
+}
+
+// step is one shell command of a run: a before step, the command's own cmd
+// or an after step.
+type step struct {
+	label string
+	cmd   string
+	after bool
+}
+
+// steps returns the command's before steps, its cmd, then its after steps.
+func (command Command) steps() []step {
+	steps := make([]step, 0, len(command.Before)+1+len(command.After))
+	for _, cmd := range command.Before {
+		steps = append(steps, step{"before: " + cmd, cmd, false})
+	}
+	steps = append(steps, step{"cmd: " + command.Cmd, command.Cmd, false})
+	for _, cmd := range command.After {
+		steps = append(steps, step{"after: " + cmd, cmd, true})
+	}
+	return steps
+}
+
+// how long the after steps of a canceled run with always_after may take
+const canceledAfterTimeout = 30 * time.Second
+
+func runProcess(command Command, t trigger, p Sender, ctx context.Context) {
+	started := time.Now()
+	canceled := false
+	send := func(status Status, duration time.Duration, output string, exitCode int) {
+		p.Send(result{
+			duration: duration,
+			status:   status,
+			job:      command,
+			output:   output,
+			trigger:  t,
+			started:  started,
+			exitCode: exitCode,
+			canceled: canceled,
+		})
+	}
+
+	send(Pending, 1, "", 0)
+	live := outputWriter{command.ID, started, p}
+	steps := command.steps()
+	// only label each step's output when there's more than one
+	labeled := len(steps) > 1
+
+	var output strings.Builder
+	status, exitCode := Succeeded, 0
+	start := time.Now() // Start timing here, before the first step starts
+	for i, s := range steps {
+		if status == Failed && (!s.after || !command.AlwaysAfter) {
+			continue
+		}
+		if labeled {
+			header := fmt.Sprintf("── %s ──\n", s.label)
+			output.WriteString(header)
+			live.Write([]byte(header))
+		}
+
+		stepOutput, code, err := runStep(command, s.cmd, live, ctx)
+		if errors.Is(err, context.Canceled) {
+			canceled = true
+			if command.AlwaysAfter {
+				runCanceledAfter(command, steps[i:], live)
+			}
+			send(Failed, time.Since(start), canceledOutput, -1)
+			return
+		}
+		if err != nil && status == Succeeded {
+			status, exitCode = Failed, code
+		}
+		output.WriteString(stepOutput)
+		if labeled && !strings.HasSuffix(stepOutput, "\n") {
+			output.WriteString("\n")
+		}
+	}
+	send(status, time.Since(start), output.String(), exitCode)
+}
+
+// runCanceledAfter runs the after steps left when a run is canceled, from
+// the one it was canceled in, limited to canceledAfterTimeout.
+func runCanceledAfter(command Command, steps []step, live io.Writer) {
+	ctx, cancel := context.WithTimeout(context.Background(), canceledAfterTimeout)
+	defer cancel()
+	for _, s := range steps {
+		if !s.after {
+			continue
+		}
+		fmt.Fprintf(live, "── %s ──\n", s.label)
+		if _, _, err := runStep(command, s.cmd, live, ctx); errors.Is(err, context.Canceled) {
+			log.Printf("After steps of canceled %s took longer than %s", command.title(), canceledAfterTimeout)
+			return
+		}
+	}
+}
+
+// runStep runs one step of a command, returning its output and exit code,
+// along with an error if it failed or context.Canceled if ctx was done
+// first.
+func runStep(command Command, step string, live io.Writer, ctx context.Context) (string, int, error) {
 	var stdout, stderr bytes.Buffer
 
-	cmd := exec.Command("sh", "-c", command.Cmd)
+	cmd := shellCommandContext(context.Background(), command.Shell, step)
 	if runtime.GOOS != "windows" {
 		cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
 	}
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+	if len(command.Env) > 0 {
+		cmd.Env = commandEnv(command.Env)
+	}
+	cmd.Stdout = io.MultiWriter(&stdout, live)
+	cmd.Stderr = io.MultiWriter(&stderr, live)
 
-	start := time.Now() // Start timing here, before command starts
-	err := cmd.Start()
-	if err != nil {
-		p.Send(result{0, Failed, command, err.Error()})
-		return
+	if err := cmd.Start(); err != nil {
+		return err.Error(), -1, err
 	}
 
 	done := make(chan error)
@@ -39,47 +201,181 @@ func runProcess(command Command, p *tea.Program, ctx context.Context) {
 	select {
 	case <-ctx.Done():
 		killProcess(cmd)
-		p.Send(result{time.Since(start), Failed, command, "Command canceled"})
+		return "", -1, context.Canceled
 	case err := <-done:
-		elapsed := time.Since(start)
 		if err != nil {
-			p.Send(result{elapsed, Failed, command, stderr.String() + "\n" + stdout.String()})
-		} else {
-			output := stdout.String()
-			if output == "" {
-				output = "No output"
+			exitCode := -1
+			var exitErr *exec.ExitError
+			if errors.As(err, &exitErr) {
+				exitCode = exitErr.ExitCode()
 			}
-			p.Send(result{elapsed, Succeeded, command, output})
+			return stderr.String() + "\n" + stdout.String(), exitCode, err
+		}
+		output := stdout.String()
+		if output == "" {
+			output = "No output"
 		}
+		return output, 0, nil
+	}
+}
+
+// shellCommandContext runs cmd with shell, as <shell> -c <cmd>, killing it
+// when ctx is done.
+func shellCommandContext(ctx context.Context, shell, cmd string) *exec.Cmd {
+	fields := strings.Fields(shell)
+	if len(fields) == 0 {
+		fields = strings.Fields(builtinDefaults.Shell)
+	}
+	args := append(fields[1:], "-c", cmd)
+	return exec.CommandContext(ctx, fields[0], args...)
+}
+
+// commandEnv returns the process environment with the command's env applied on top.
+func commandEnv(env map[string]string) []string {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	vars := os.Environ()
+	for _, k := range keys {
+		vars = append(vars, k+"="+env[k])
+	}
+	return vars
+}
+
+// runControl lets a command's current run be canceled and watching be
+// paused from outside the process running it, such as from the API.
+type runControl struct {
+	mu      sync.Mutex
+	cancels map[int]*context.CancelFunc
+	// held by each command's current run, so a canceled run finishes its
+	// after steps before the next one starts
+	runs   map[int]*sync.Mutex
+	paused bool
+}
+
+func newRunControl() *runControl {
+	return &runControl{cancels: make(map[int]*context.CancelFunc), runs: make(map[int]*sync.Mutex)}
+}
+
+// exclusive waits for command id's previous run to finish, returning a func
+// to call when this one does.
+func (c *runControl) exclusive(id int) func() {
+	if c == nil {
+		return func() {}
+	}
+	c.mu.Lock()
+	run, ok := c.runs[id]
+	if !ok {
+		run = &sync.Mutex{}
+		c.runs[id] = run
+	}
+	c.mu.Unlock()
+
+	run.Lock()
+	return run.Unlock
+}
+
+// track returns a context for a run of command id that cancel(id) cancels,
+// and a func to call when the run finishes.
+func (c *runControl) track(ctx context.Context, id int) (context.Context, func()) {
+	if c == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	c.mu.Lock()
+	c.cancels[id] = &cancel
+	c.mu.Unlock()
+
+	return ctx, func() {
+		cancel()
+		c.mu.Lock()
+		// unless a newer run has started
+		if c.cancels[id] == &cancel {
+			delete(c.cancels, id)
+		}
+		c.mu.Unlock()
+	}
+}
+
+// cancel cancels command id's current run, returning whether it had one.
+func (c *runControl) cancel(id int) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cancel, ok := c.cancels[id]
+	if ok {
+		(*cancel)()
+		delete(c.cancels, id)
+	}
+	return ok
+}
+
+func (c *runControl) setPaused(paused bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	c.paused = paused
+	c.mu.Unlock()
+}
+
+// isPaused reports whether file changes are being ignored.
+func (c *runControl) isPaused() bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.paused
+}
+
+// runTracked runs command so that its run can be canceled by the model's
+// runControl, once its previous run has finished. It's only tracked once
+// it starts, so canceling reaches the run in progress rather than one
+// waiting for it.
+func (m model) runTracked(command Command, t trigger, p Sender, ctx context.Context) {
+	unlock := m.control.exclusive(command.ID)
+	defer unlock()
+	// superseded while waiting
+	if ctx.Err() != nil {
+		return
 	}
+	ctx, done := m.control.track(ctx, command.ID)
+	defer done()
+	runProcess(command, t, p, ctx)
 }
 
 func executeCommand(m model, id int) {
 	log.Println("Attempting to trigger command:", m.commands[id].Cmd)
 
 	select {
-	case m.triggerChans[id] <- true:
+	case m.triggerChans[id] <- trigger{reason: triggerManual}:
 		log.Println("Trigger sent successfully")
 	default:
 		log.Println("Channel already has a value")
 	}
 }
 
-func RunAll(m model, p *tea.Program, context context.Context) {
+func RunAll(m model, p Sender, context context.Context) {
 	log.Println("Running all commands...")
 
 	for _, cmd := range m.commands {
-		go runProcess(cmd, p, context)
+		go m.runTracked(cmd, trigger{reason: triggerStart}, p, context)
 	}
 }
 
-func WatchForTriggers(m model, p *tea.Program, ctx context.Context) {
+func WatchForTriggers(m model, p Sender, ctx context.Context) {
 	for id := range m.commands {
 		go func(cmdId int) {
 			for {
 				log.Println("Waiting for trigger for command:", m.commands[cmdId].Cmd)
-				<-m.triggerChans[cmdId]
-				runProcess(m.commands[cmdId], p, ctx)
+				t := <-m.triggerChans[cmdId]
+				m.runTracked(m.commands[cmdId], t, p, ctx)
 			}
 		}(id)
 	}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder keeps a run's final result and its live output.
+type recorder struct {
+	mu   sync.Mutex
+	res  result
+	live strings.Builder
+}
+
+func (r *recorder) Send(msg tea.Msg) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch msg := msg.(type) {
+	case result:
+		r.res = msg
+	case outputMsg:
+		r.live.WriteString(msg.chunk)
+	}
+}
+
+func TestRunProcess(t *testing.T) {
+	var r recorder
+	runProcess(Command{Cmd: "echo fine", Shell: "sh"}, trigger{reason: triggerStart}, &r, context.Background())
+	require.Equal(t, Succeeded, r.res.status)
+	require.Equal(t, "fine\n", r.res.output)
+
+	runProcess(Command{Cmd: "echo broken >&2; exit 3", Shell: "sh"}, trigger{reason: triggerStart}, &r, context.Background())
+	require.Equal(t, Failed, r.res.status)
+	require.Equal(t, 3, r.res.exitCode)
+	require.Equal(t, "broken\n\n", r.res.output)
+}
+
+func TestRunProcessSteps(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	command := Command{
+		Cmd:    "echo test >> " + log + "; echo testing",
+		Shell:  "sh",
+		Before: []string{"echo db up >> " + log, "true"},
+		After:  []string{"echo db down >> " + log},
+	}
+
+	var r recorder
+	runProcess(command, trigger{reason: triggerStart}, &r, context.Background())
+	require.Equal(t, Succeeded, r.res.status)
+	require.Equal(t, "── before: echo db up >> "+log+" ──\nNo output\n"+
+		"── before: true ──\nNo output\n"+
+		"── cmd: "+command.Cmd+" ──\ntesting\n"+
+		"── after: echo db down >> "+log+" ──\nNo output\n", r.res.output)
+	require.Contains(t, r.live.String(), "── cmd: "+command.Cmd+" ──\ntesting\n── after:")
+	requireLog(t, log, "db up", "test", "db down")
+
+	// a failing before step skips cmd and after
+	require.NoError(t, os.Remove(log))
+	command.Before = []string{"echo db up >> " + log, "exit 2"}
+	runProcess(command, trigger{reason: triggerStart}, &r, context.Background())
+	require.Equal(t, Failed, r.res.status)
+	require.Equal(t, 2, r.res.exitCode)
+	require.NotContains(t, r.res.output, "── cmd:")
+	requireLog(t, log, "db up")
+
+	// unless after always runs
+	require.NoError(t, os.Remove(log))
+	command.AlwaysAfter = true
+	runProcess(command, trigger{reason: triggerStart}, &r, context.Background())
+	require.Equal(t, Failed, r.res.status)
+	require.Equal(t, 2, r.res.exitCode)
+	require.Contains(t, r.res.output, "── after:")
+	requireLog(t, log, "db up", "db down")
+
+	// a failing after step fails the run
+	command.Before = nil
+	command.After = []string{"exit 4"}
+	runProcess(command, trigger{reason: triggerStart}, &r, context.Background())
+	require.Equal(t, Failed, r.res.status)
+	require.Equal(t, 4, r.res.exitCode)
+}
+
+func TestRunProcessStepsCanceled(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	command := Command{
+		Cmd:   "sleep 10",
+		Shell: "sh",
+		After: []string{"echo db down >> " + log},
+	}
+
+	// canceled mid-cmd
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	var r recorder
+	runProcess(command, trigger{reason: triggerStart}, &r, ctx)
+	require.True(t, r.res.canceled)
+	require.Equal(t, canceledOutput, r.res.output)
+	require.NoFileExists(t, log)
+
+	command.AlwaysAfter = true
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	runProcess(command, trigger{reason: triggerStart}, &r, ctx)
+	require.True(t, r.res.canceled)
+	requireLog(t, log, "db down")
+	require.Contains(t, r.live.String(), "── after: echo db down >> "+log+" ──")
+}
+
+func TestRunTrackedWaitsForAfter(t *testing.T) {
+	dir := t.TempDir()
+	log := filepath.Join(dir, "log")
+	command := Command{
+		Cmd:         "sleep 10",
+		Shell:       "sh",
+		Before:      []string{"echo db up >> " + log},
+		After:       []string{"sleep 0.2; echo db down >> " + log},
+		AlwaysAfter: true,
+	}
+	m := model{control: newRunControl()}
+
+	var wg sync.WaitGroup
+	first, cancelFirst := context.WithCancel(context.Background())
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.runTracked(command, trigger{reason: triggerStart}, &recorder{}, first)
+	}()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(log)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// a change cancels the run and starts the next
+	cancelFirst()
+	second, cancelSecond := context.WithTimeout(context.Background(), time.Second)
+	defer cancelSecond()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		m.runTracked(command, trigger{reason: triggerChange}, &recorder{}, second)
+	}()
+	wg.Wait()
+
+	requireLog(t, log, "db up", "db down", "db up", "db down")
+}
+
+func requireLog(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, strings.Join(lines, "\n")+"\n", string(data))
+}
@@ -2,10 +2,13 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"time"
 
 	"github.com/charmbracelet/bubbles/key"
@@ -15,6 +18,7 @@ import (
 	"github.com/charmbracelet/bubbles/viewport"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/gobwas/glob"
+	"golang.org/x/term"
 	"gopkg.in/yaml.v3"
 )
 
@@ -24,8 +28,9 @@ const (
 	Pending Status = iota
 	Succeeded
 	Failed
-	commandFile = "./panopticon.yaml"
-	configFile  = "config.yaml"
+	commandFile     = "./panopticon.yaml"
+	commandFileBase = "./panopticon"
+	configFileBase  = "config"
 )
 
 func (s Status) String() string {
@@ -37,48 +42,157 @@ type result struct {
 	status   Status
 	job      Command
 	output   string
+	trigger  trigger
+	started  time.Time
+	// -1 if the command didn't start, was killed or was canceled
+	exitCode int
+	// the run was canceled by a newer one, by hand or by quitting
+	canceled bool
 }
 
+// output of a canceled run
+const canceledOutput = "Command canceled"
+
 type model struct {
 	spinner         spinner.Model
 	results         map[int]result
-	triggerChans    []chan bool
+	triggerChans    []chan trigger
 	quitting        bool
 	commands        []Command
 	progress        progress.Model
 	list            list.Model
 	currentViewport *viewport.Model
 	currentSelected int
-	cancelAll       context.CancelFunc
-	theme           Theme
+	pager           *pager
+	layout          string
+	pane            viewport.Model
+	paneSelected    int
+	// the body of each running command's item, its output added as it comes
+	live          map[int]*strings.Builder
+	locations     map[int][]location
+	errorIndex    int
+	errorSelected int
+	problems      *problemFiles
+	reports       *reporter
+	// writes terminal notifications to stderr, keeping them out of output
+	// piped or parsed from stdout
+	notifier    *notifier
+	hooks       *hookRunner
+	control     *runControl
+	history     map[int][]pastRun
+	runCounts   map[int]int
+	historyView *historyView
+	cancelAll   context.CancelFunc
+	theme       Theme
+	keys        keyBindings
 }
 
 type Command struct {
-	ID          int
-	Cmd         string   `yaml:"cmd" validate:"required"`
-	WatchPaths  []string `yaml:"watch_paths" validate:"required"`
-	IgnorePaths []string `yaml:"ignore_paths,omitempty"`
+	ID             int               `yaml:"-"`
+	Name           string            `yaml:"name,omitempty" desc:"Name shown in place of the command."`
+	Cmd            string            `yaml:"cmd" validate:"required" desc:"Shell command to run. Required unless set in defaults."`
+	WatchPaths     []string          `yaml:"watch_paths" validate:"required" desc:"Paths watched recursively for changes. Required unless set in defaults."`
+	IgnorePaths    []string          `yaml:"ignore_paths,omitempty" desc:"Paths excluded from watching."`
+	Env            map[string]string `yaml:"env,omitempty" desc:"Environment variables set for the command."`
+	Shell          string            `yaml:"shell,omitempty" desc:"Shell the command is run with as <shell> -c <cmd>. Defaults to sh."`
+	Debounce       time.Duration     `yaml:"debounce,omitempty" desc:"How long to wait for changes to settle before running, such as 300ms. By default each change runs the command right away."`
+	IgnorePatterns []string          `yaml:"ignore_patterns,omitempty" desc:"Glob patterns of file and directory names or relative paths to ignore, such as *.swp or **/node_modules."`
+	Matchers       []string          `yaml:"matchers,omitempty" desc:"Regular expressions finding error locations in output, with named groups file, line and optionally column, severity and message. Tried before the built-in Go, TypeScript, ESLint, Rust and Python matchers."`
+	NotifyOn       []string          `yaml:"notify_on,omitempty" enum:"notify_on" desc:"When to notify: broken when a run fails after a success, fixed when one succeeds after a failure, every failure or success, or none to turn off notifications from defaults."`
+	NotifyWith     []string          `yaml:"notify_with,omitempty" enum:"notify_with" desc:"How to notify: a terminal bell, an OSC 9 or OSC 777 desktop notification through the terminal, or running notify_cmd. Defaults to command if notify_cmd is set, otherwise bell."`
+	NotifyCmd      string            `yaml:"notify_cmd,omitempty" desc:"Shell command run to notify, such as notify-send \"$PANOPTICON_MESSAGE\", with PANOPTICON_COMMAND, PANOPTICON_STATUS, PANOPTICON_TRANSITION, PANOPTICON_MESSAGE, PANOPTICON_EXIT_CODE and PANOPTICON_DURATION_MS set."`
+	NotifyInterval time.Duration     `yaml:"notify_interval,omitempty" desc:"Least time between notifications for the command, such as 1m, with a transition made sooner sent when it ends. Defaults to 10s."`
+	Before         []string          `yaml:"before,omitempty" desc:"Shell commands run in order before cmd, such as starting a test database. If one fails, the run fails without running cmd."`
+	After          []string          `yaml:"after,omitempty" desc:"Shell commands run in order after cmd succeeds, such as cleaning up. A failing after command fails the run."`
+	AlwaysAfter    bool              `yaml:"always_after,omitempty" desc:"Run the after commands even when a before command or cmd fails or the run is canceled, in which case they have 30s to finish before the next run starts."`
+	Hooks          `yaml:",inline"`
+}
+
+// title is how the command is shown in the list and in output.
+func (c Command) title() string {
+	if c.Name != "" {
+		return c.Name
+	}
+	return c.Cmd
 }
 
 type Theme struct {
-	Foreground string `yaml:"foreground"`
-	Primary    string `yaml:"primary"`
-	Secondary  string `yaml:"secondary"`
-	Tertiary   string `yaml:"tertiary"`
-	Neutral    string `yaml:"neutral"`
+	Foreground string `yaml:"foreground" desc:"Color of item text and list title."`
+	Primary    string `yaml:"primary" desc:"Start color of the progress bar gradient."`
+	Secondary  string `yaml:"secondary" desc:"End color of the progress bar gradient."`
+	Tertiary   string `yaml:"tertiary" desc:"Color of the spinner."`
+	Neutral    string `yaml:"neutral" desc:"Background of the list title."`
+	Added      string `yaml:"added,omitempty" desc:"Color of added lines in diffs. Defaults to secondary."`
+	Removed    string `yaml:"removed,omitempty" desc:"Color of removed lines in diffs. Defaults to primary."`
+}
+
+func (t Theme) added() string {
+	if t.Added != "" {
+		return t.Added
+	}
+	return t.Secondary
+}
+
+func (t Theme) removed() string {
+	if t.Removed != "" {
+		return t.Removed
+	}
+	return t.Primary
 }
 
 type Config struct {
-	ThemePreset string `yaml:"theme_preset"`
-	ThemeConfig Theme  `yaml:"theme"`
+	Schema      string    `yaml:"$schema,omitempty" desc:"JSON schema of this file, for editors."`
+	ThemePreset string    `yaml:"theme_preset" enum:"theme" desc:"Built-in theme to use. Overrides theme colors."`
+	ThemeConfig Theme     `yaml:"theme" desc:"Custom theme colors, used when no preset is set."`
+	Defaults    Command   `yaml:"defaults,omitempty" desc:"Fields inherited by every command in every project, unless set in the project's defaults."`
+	Commands    []Command `yaml:"commands,omitempty" desc:"Personal commands appended to every project's commands."`
+	Keys        KeyMap    `yaml:"keys,omitempty" desc:"Key bindings, unless set in the project config."`
+	Layout      string    `yaml:"layout,omitempty" enum:"layout" desc:"Where output is shown: inline under the selected command, split beside the list or stacked below it. Defaults to inline."`
 }
 
 type CommandConfig struct {
-	Commands []Command `yaml:"commands"`
+	Schema   string            `yaml:"$schema,omitempty" desc:"JSON schema of this file, for editors."`
+	Theme    string            `yaml:"theme,omitempty" enum:"theme" desc:"Theme preset for this project. \"default\" defers to the user config."`
+	Vars     map[string]string `yaml:"vars,omitempty" desc:"Variables referenced as ${name} in commands, paths and env values."`
+	Defaults Command           `yaml:"defaults,omitempty" desc:"Fields inherited by every command that leaves them unset."`
+	Commands []Command         `yaml:"commands" desc:"Commands to run when their watch paths change."`
+	Imports  []Import          `yaml:"imports,omitempty" desc:"Files to import more commands from, such as a Procfile, Makefile or package.json."`
+	Keys     KeyMap            `yaml:"keys,omitempty" desc:"Key bindings for this project."`
+	Layout   string            `yaml:"layout,omitempty" enum:"layout" desc:"Where output is shown for this project: inline, split or stacked."`
+	Reports  Reports           `yaml:"reports,omitempty" desc:"Report files rewritten whenever a run finishes, in the TUI, --no-tui and run."`
+	Hooks    `yaml:",inline"`
+}
+
+// configEntry is a command as written in the config, along with the node
+// it came from and how to refer to it in diagnostics.
+type configEntry struct {
+	cmd   Command
+	file  string
+	doc   *yaml.Node
+	node  *yaml.Node
+	label string
+}
+
+// Options holds settings given on the command line, which take precedence
+// over the project config, then the user config, then built-in defaults.
+type Options struct {
+	Theme    string
+	Strict   bool
+	Shell    string
+	Debounce time.Duration
+	Layout   string
+	// report paths, overriding the project config
+	JUnit    string
+	Markdown string
+}
+
+// builtinDefaults are used for anything neither config sets.
+var builtinDefaults = Command{
+	Shell: "sh",
 }
 
-func NewModel(cancel context.CancelFunc, g glob.Glob, themeOverride string) model {
-	config, commandConfig, err := loadConfig(themeOverride)
+func NewModel(cancel context.CancelFunc, g glob.Glob, opts Options) model {
+	config, commandConfig, err := loadConfig(opts)
 	if err != nil {
 		fmt.Println("Error loading config:", err)
 		os.Exit(1)
@@ -87,8 +201,9 @@ func NewModel(cancel context.CancelFunc, g glob.Glob, themeOverride string) mode
 	var commands []Command
 	var i int
 	for _, cmd := range commandConfig.Commands {
-		if g.Match(cmd.Cmd) {
-			commands = append(commands, Command{i, cmd.Cmd, cmd.WatchPaths, cmd.IgnorePaths})
+		if g.Match(cmd.Cmd) || (cmd.Name != "" && g.Match(cmd.Name)) {
+			cmd.ID = i
+			commands = append(commands, cmd)
 			i++
 		}
 	}
@@ -112,29 +227,26 @@ func NewModel(cancel context.CancelFunc, g glob.Glob, themeOverride string) mode
 		Foreground(lipgloss.Color(config.ThemeConfig.Foreground)).
 		Padding(0, 1)
 	list.SetShowStatusBar(false)
+	keys := newKeyBindings(config.Keys)
 	list.AdditionalShortHelpKeys = func() []key.Binding {
 		return []key.Binding{
-			key.NewBinding(
-				key.WithKeys("ctrl+j", "ctrl+down"),
-				key.WithHelp("ctrl+j/ctrl+↓", "scroll down in viewport"),
-			),
-			key.NewBinding(
-				key.WithKeys("ctrl+k", "ctrl+up"),
-				key.WithHelp("ctrl+k/ctrl+↑", "scroll up in viewport"),
-			),
-			key.NewBinding(
-				key.WithKeys("r"),
-				key.WithHelp("r", "run command now"),
-			),
+			keys.scrollDown,
+			keys.scrollUp,
+			keys.run,
+			keys.pager,
+			keys.history,
+			keys.diff,
+			keys.nextError,
+			keys.openError,
 		}
 	}
 	list.DisableQuitKeybindings()
 	list.SetFilteringEnabled(false)
 	list.SetShowFilter(false)
 
-	triggerChans := make([]chan bool, len(commands))
+	triggerChans := make([]chan trigger, len(commands))
 	for i := range commands {
-		triggerChans[i] = make(chan bool, 1) // Buffered channel
+		triggerChans[i] = make(chan trigger, 1) // Buffered channel
 	}
 
 	newModel := model{
@@ -147,6 +259,20 @@ func NewModel(cancel context.CancelFunc, g glob.Glob, themeOverride string) mode
 		cancelAll:       cancel,
 		triggerChans:    triggerChans,
 		theme:           config.ThemeConfig,
+		keys:            keys,
+		layout:          config.Layout,
+		pane:            viewport.New(0, 0),
+		paneSelected:    -1,
+		live:            make(map[int]*strings.Builder, len(commands)),
+		locations:       make(map[int][]location, len(commands)),
+		errorIndex:      -1,
+		problems:        newProblemFiles(stateDir),
+		reports:         newReporter(commandConfig.Reports, commands),
+		control:         newRunControl(),
+		notifier:        newNotifier(os.Stderr, term.IsTerminal(int(os.Stderr.Fd()))),
+		hooks:           newHookRunner(commandConfig.Hooks, commands),
+		history:         make(map[int][]pastRun, len(commands)),
+		runCounts:       make(map[int]int, len(commands)),
 	}
 
 	setSizes(newModel)
@@ -154,102 +280,173 @@ func NewModel(cancel context.CancelFunc, g glob.Glob, themeOverride string) mode
 	return newModel
 }
 
-func loadConfig(themeOverride string) (Config, CommandConfig, error) {
+func loadConfig(opts Options) (Config, CommandConfig, error) {
+	conf, commandConf, diagnostics, err := readConfig(opts)
 	// Check if the config file exists
-	if _, err := os.Stat(commandFile); os.IsNotExist(err) {
+	if errors.Is(err, os.ErrNotExist) {
 		log.Println("Config file not found, please run panopticon init or create one.")
 		return Config{}, CommandConfig{}, err
 	}
+	if err != nil {
+		return conf, commandConf, err
+	}
+
+	for _, d := range diagnostics {
+		log.Println(d)
+	}
+	if opts.Strict && len(diagnostics) > 0 {
+		return conf, commandConf, &ConfigError{diagnostics}
+	}
+
+	return conf, commandConf, nil
+}
 
+// readConfig loads the project and user config, returning any problems
+// found along the way as diagnostics rather than failing on the first one.
+func readConfig(opts Options) (Config, CommandConfig, []Diagnostic, error) {
 	var conf Config
 	var commandConf CommandConfig
 
-	commandData, err := os.ReadFile(commandFile)
+	var diagnostics []Diagnostic
+	projectFile, err := findConfigFile(commandFileBase)
+	if errors.Is(err, os.ErrNotExist) {
+		return conf, commandConf, nil, err
+	}
 	if err != nil {
-		return conf, commandConf, err
+		diagnostics = append(diagnostics, Diagnostic{File: filepath.Clean(projectFile), Message: err.Error()})
 	}
 
-	err = yaml.Unmarshal(commandData, &commandConf)
+	commandRoot, commandDiagnostics, err := parseConfigFile(projectFile, &commandConf)
+	diagnostics = append(diagnostics, commandDiagnostics...)
+	if err != nil {
+		return conf, commandConf, diagnostics, err
+	}
 
-	configFile, _ := getConfigPath()
-	if err == nil {
-		configData, _ := os.ReadFile(configFile)
+	configFile, err := getConfigPath()
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		diagnostics = append(diagnostics, Diagnostic{File: configFile, Message: err.Error()})
+	}
+	var configRoot *yaml.Node
+	if configFile != "" {
+		var configDiagnostics []Diagnostic
+		configRoot, configDiagnostics, err = parseConfigFile(configFile, &conf)
+		if err != nil {
+			return conf, commandConf, append(diagnostics, configDiagnostics...), err
+		}
+		diagnostics = append(diagnostics, configDiagnostics...)
+		diagnostics = append(diagnostics, validateUserConfig(configFile, configRoot, conf)...)
+	}
 
-		err = yaml.Unmarshal(configData, &conf)
+	vars, err := resolveVars(commandConf.Vars)
+	if err != nil {
+		return conf, commandConf, diagnostics, err
 	}
-	var commands []Command
+
+	doc := documentNode(commandRoot)
+	var entries []configEntry
 	for i, cmd := range commandConf.Commands {
+		entries = append(entries, configEntry{cmd, projectFile, doc, sequenceItem(doc, "commands", i), fmt.Sprintf("commands[%d]", i)})
+	}
+	imported, importDiagnostics := importEntries(projectFile, doc, commandConf.Imports)
+	entries = append(entries, imported...)
+	diagnostics = append(diagnostics, importDiagnostics...)
+
+	// personal commands from the user config go after the project's own
+	userDoc := documentNode(configRoot)
+	for i, cmd := range conf.Commands {
+		entries = append(entries, configEntry{cmd, configFile, userDoc, sequenceItem(userDoc, "commands", i), fmt.Sprintf("commands[%d]", i)})
+	}
+
+	defaults := applyDefaults(applyDefaults(commandConf.Defaults, conf.Defaults), builtinDefaults)
+
+	var commands []Command
+	for i, entry := range entries {
+		entry.cmd = expandCommand(entry.cmd, defaults, vars)
+		if opts.Shell != "" {
+			entry.cmd.Shell = opts.Shell
+		}
+		if opts.Debounce != 0 {
+			entry.cmd.Debounce = opts.Debounce
+		}
+		diagnostics = append(diagnostics, validateCommand(entry)...)
+		cmd := entry.cmd
+
 		// Get absolute path for each watch path
 		var watchPaths []string
 		for _, watchPath := range cmd.WatchPaths {
-			absPath, _ := getAbsolutePath(watchPath)
+			absPath, err := getAbsolutePath(watchPath)
+			if err != nil {
+				return conf, commandConf, diagnostics, err
+			}
 			watchPaths = append(watchPaths, absPath)
 		}
 
 		// Get absolute path for each ignore path
 		var ignorePaths []string
 		for _, ignorePath := range cmd.IgnorePaths {
-			absPath, _ := getAbsolutePath(ignorePath)
+			absPath, err := getAbsolutePath(ignorePath)
+			if err != nil {
+				return conf, commandConf, diagnostics, err
+			}
 			ignorePaths = append(ignorePaths, absPath)
 		}
 
-		commands = append(commands, Command{
-			ID:          i,
-			Cmd:         cmd.Cmd,
-			WatchPaths:  watchPaths,
-			IgnorePaths: ignorePaths,
-		})
+		cmd.ID = i
+		cmd.WatchPaths = watchPaths
+		cmd.IgnorePaths = ignorePaths
+		commands = append(commands, cmd)
 	}
-
-	if themeOverride != "" {
-		conf.ThemePreset = themeOverride
+	conf.Keys = applyKeyDefaults(applyKeyDefaults(commandConf.Keys, conf.Keys), defaultKeys)
+	diagnostics = append(diagnostics, validateProjectTheme(projectFile, commandRoot, commandConf.Theme)...)
+	diagnostics = append(diagnostics, validateLayout(projectFile, commandRoot, commandConf.Layout)...)
+	commandConf.Hooks = commandConf.Hooks.interpolate(vars)
+	diagnostics = append(diagnostics, validateHooks(projectFile, doc, "", commandConf.Hooks)...)
+	diagnostics = append(diagnostics, validateDefaultsHooks(projectFile, doc)...)
+	if configFile != "" {
+		diagnostics = append(diagnostics, validateLayout(configFile, configRoot, conf.Layout)...)
+		diagnostics = append(diagnostics, validateDefaultsHooks(configFile, userDoc)...)
 	}
 
-	if (conf.ThemePreset == "" || conf.ThemePreset == "default") && conf.ThemeConfig == (Theme{}) {
-		conf.ThemePreset = "catppuccin"
-		conf.ThemeConfig = catppuccin
-	} else {
-		switch conf.ThemePreset {
-		case "catppuccin":
-			conf.ThemeConfig = catppuccin
-		case "gruvbox":
-			conf.ThemeConfig = gruvbox
-		case "dracula":
-			conf.ThemeConfig = dracula
-		case "solarized":
-			conf.ThemeConfig = solarized
-		case "nord":
-			conf.ThemeConfig = nord
-		case "tokyonight":
-			conf.ThemeConfig = tokyonight
-		default:
-			if conf.ThemeConfig == (Theme{}) {
-				log.Println("Invalid theme config, using default theme")
-				conf.ThemeConfig = catppuccin
-			}
+	if commandConf.Layout != "" {
+		conf.Layout = commandConf.Layout
+	}
+	if opts.Layout != "" {
+		if !validLayout(opts.Layout) {
+			return conf, commandConf, diagnostics, fmt.Errorf("unknown layout %q (expected one of %s)", opts.Layout, strings.Join(layouts, ", "))
 		}
+		conf.Layout = opts.Layout
+	}
+	if !validLayout(conf.Layout) {
+		conf.Layout = layoutInline
 	}
 
-	return Config{conf.ThemePreset, conf.ThemeConfig}, CommandConfig{commands}, err
-}
+	if opts.JUnit != "" {
+		commandConf.Reports.JUnit = opts.JUnit
+	}
+	if opts.Markdown != "" {
+		commandConf.Reports.Markdown = opts.Markdown
+	}
 
-func InitConfig() error {
-	// don't if file exists
-	if _, err := os.Stat("panopticon.yaml"); err == nil {
-		log.Println("panopticon.yaml already exists")
-		return nil
+	// "default" in the project config defers to the user config
+	if commandConf.Theme != "" && commandConf.Theme != "default" {
+		conf.ThemePreset = commandConf.Theme
+	}
+	if opts.Theme != "" {
+		conf.ThemePreset = opts.Theme
 	}
 
-	content := []byte(`# yaml-language-server: $schema=panopticon.schema.json
-commands:
-  - cmd: "echo 'Hello, World!'"
-    watch_paths:
-      - ./
-theme: "default"
-`)
+	if (conf.ThemePreset == "" || conf.ThemePreset == "default") && conf.ThemeConfig == (Theme{}) {
+		conf.ThemePreset = "catppuccin"
+		conf.ThemeConfig = catppuccin
+	} else if preset, ok := themePresets[conf.ThemePreset]; ok {
+		conf.ThemeConfig = preset
+	} else if conf.ThemeConfig == (Theme{}) {
+		log.Println("Invalid theme config, using default theme")
+		conf.ThemeConfig = catppuccin
+	}
 
-	log.Println("Creating sample panopticon.yaml")
-	return os.WriteFile("panopticon.yaml", content, 0o644)
+	commandConf.Commands = commands
+	return conf, commandConf, diagnostics, nil
 }
 
 func getConfigPath() (string, error) {
@@ -265,9 +462,9 @@ func getConfigPath() (string, error) {
 		}
 	}
 
-	if _, err := os.Stat(configDir + "/panopticon/" + configFile); err == nil {
-		return configDir + "/panopticon/" + configFile, nil
+	path, err := findConfigFile(filepath.Join(configDir, "panopticon", configFileBase))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("config file not found: %w", err)
 	}
-
-	return "", fmt.Errorf("config file not found")
+	return path, err
 }
@@ -0,0 +1,285 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const templatedConfig = `vars:
+  pkg: ./internal/...
+  flags: -race ${env:PAN_TEST_FLAGS}
+defaults: &defaults
+  watch_paths: ['./']
+  ignore_paths: ['.git']
+  env:
+    GOFLAGS: -mod=mod
+commands:
+  - cmd: go test ${flags} ${pkg}
+  - <<: *defaults
+    cmd: echo $${HOME} ${HOME}
+    ignore_paths: ['.github']
+    env:
+      EXTRA: ${pkg}
+`
+
+func TestLoadConfigTemplating(t *testing.T) {
+	t.Setenv("PAN_TEST_FLAGS", "-count=1")
+	err := os.WriteFile(commandFile, []byte(templatedConfig), 0o644)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+	}()
+
+	_, commandConf, err := loadConfig(Options{})
+	require.NoError(t, err)
+	require.Len(t, commandConf.Commands, 2)
+
+	pwd, _ := os.Getwd()
+
+	first := commandConf.Commands[0]
+	require.Equal(t, "go test -race -count=1 ./internal/...", first.Cmd)
+	require.Equal(t, []string{pwd}, first.WatchPaths)
+	require.Equal(t, []string{pwd + "/.git"}, first.IgnorePaths)
+	require.Equal(t, map[string]string{"GOFLAGS": "-mod=mod"}, first.Env)
+
+	second := commandConf.Commands[1]
+	require.Equal(t, "echo ${HOME} ${HOME}", second.Cmd)
+	require.Equal(t, []string{pwd + "/.github"}, second.IgnorePaths)
+	require.Equal(t, map[string]string{"GOFLAGS": "-mod=mod", "EXTRA": "./internal/..."}, second.Env)
+}
+
+func TestResolveVarsCycle(t *testing.T) {
+	_, err := resolveVars(map[string]string{"a": "${b}", "b": "${a}"})
+	require.ErrorContains(t, err, "cycle")
+}
+
+const invalidConfig = `theme: nrod
+commands:
+  - cmd: go build
+    watch_path: ['./']
+  - cmd: go test
+    watch_paths: ['./missing']
+defaults:
+  on_failure:
+    - cmd: ./page.sh
+`
+
+func TestValidate(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	err := os.WriteFile(commandFile, []byte(invalidConfig), 0o644)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+	}()
+
+	diagnostics, err := Validate()
+	require.NoError(t, err)
+
+	var got []string
+	for _, d := range diagnostics {
+		got = append(got, d.String())
+	}
+	require.Equal(t, []string{
+		`panopticon.yaml:1:8: unknown theme "nrod" (expected one of catppuccin, dracula, gruvbox, nord, solarized, tokyonight)`,
+		`panopticon.yaml:3:5: commands[0]: missing required field "watch_paths"`,
+		`panopticon.yaml:4:5: unknown field "watch_path", did you mean "watch_paths"?`,
+		`panopticon.yaml:6:19: commands[1]: watch path "./missing" does not exist`,
+		`panopticon.yaml:9:5: defaults: on_failure isn't inherited, set it at the top level to run it for every command`,
+	}, got)
+
+	_, _, err = loadConfig(Options{Strict: true})
+	var configErr *ConfigError
+	require.ErrorAs(t, err, &configErr)
+	require.Len(t, configErr.Diagnostics, 5)
+}
+
+func TestSchemaUpToDate(t *testing.T) {
+	for _, name := range SchemaNames() {
+		generated, err := Schema(name)
+		require.NoError(t, err)
+
+		existing, err := os.ReadFile("../" + SchemaFile(name))
+		require.NoError(t, err)
+
+		require.Equal(t, string(existing), string(generated), "%s is out of date, run panopticon schema -w", SchemaFile(name))
+	}
+}
+
+func TestLoadConfigImports(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	config := "defaults:\n  watch_paths: ['./']\ncommands:\n  - cmd: go build\nimports:\n  - from: Procfile.test\n    only: ['web*']\n"
+	require.NoError(t, os.WriteFile(commandFile, []byte(config), 0o644))
+	require.NoError(t, os.WriteFile("Procfile.test", []byte("web: go run . serve\nweb-assets: npm run watch\nworker: go run . work\n"), 0o644))
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+		_ = os.RemoveAll("Procfile.test")
+	}()
+
+	_, commandConf, err := loadConfig(Options{Strict: true})
+	require.NoError(t, err)
+
+	var got [][2]string
+	for _, cmd := range commandConf.Commands {
+		got = append(got, [2]string{cmd.Name, cmd.Cmd})
+	}
+	require.Equal(t, [][2]string{
+		{"", "go build"},
+		{"web", "go run . serve"},
+		{"web-assets", "npm run watch"},
+	}, got)
+	require.Equal(t, 2, commandConf.Commands[2].ID)
+}
+
+func TestAddImports(t *testing.T) {
+	require.NoError(t, os.WriteFile(commandFile, []byte("imports:\n  - from: Procfile.test\n    watch_paths: [./]\n"), 0o644))
+	require.NoError(t, os.WriteFile("Procfile.test", []byte("web: go run . serve\n"), 0o644))
+	require.NoError(t, os.WriteFile("test.mk", []byte("lint:\n\tgolangci-lint run\n"), 0o644))
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+		_ = os.RemoveAll("Procfile.test")
+		_ = os.RemoveAll("test.mk")
+	}()
+
+	added, err := AddImports([]string{"Procfile.test", "test.mk"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"test.mk"}, added.Added)
+	require.Equal(t, []string{"Procfile.test"}, added.Skipped)
+	require.Len(t, added.Commands, 1)
+	require.Equal(t, "lint", added.Commands[0].Name)
+
+	data, err := os.ReadFile(commandFile)
+	require.NoError(t, err)
+	// without watch paths in defaults, the new import watches the project
+	require.Equal(t, "imports:\n  - from: Procfile.test\n    watch_paths: [./]\n  - from: test.mk\n    watch_paths:\n      - ./\n", string(data))
+	_, conf, err := loadConfig(Options{Strict: true})
+	require.NoError(t, err)
+	require.Len(t, conf.Commands, 2)
+	require.Equal(t, "lint", conf.Commands[1].Name)
+	require.NotEmpty(t, conf.Commands[1].WatchPaths)
+
+	// with them, it's left to the defaults
+	require.NoError(t, os.WriteFile(commandFile, []byte("defaults:\n  watch_paths: [./]\n"), 0o644))
+	_, err = AddImports([]string{"test.mk"})
+	require.NoError(t, err)
+	data, err = os.ReadFile(commandFile)
+	require.NoError(t, err)
+	require.Equal(t, "defaults:\n  watch_paths: [./]\nimports:\n  - from: test.mk\n", string(data))
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	userDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	require.NoError(t, os.MkdirAll(userDir+"/panopticon", 0o755))
+	require.NoError(t, os.WriteFile(userDir+"/panopticon/config.json", []byte(`{"theme_preset": "nord"}`), 0o644))
+
+	configs := map[string]string{
+		"./panopticon.toml": `[vars]
+flags = "-race"
+
+[[commands]]
+cmd = "go test ${flags}"
+watch_paths = ["./"]
+env = { GOFLAGS = "-mod=mod" }
+`,
+		"./panopticon.json": `{
+  "vars": {"flags": "-race"},
+  "commands": [
+    {"cmd": "go test ${flags}", "watch_paths": ["./"], "env": {"GOFLAGS": "-mod=mod"}}
+  ]
+}`,
+	}
+
+	pwd, _ := os.Getwd()
+	for file, content := range configs {
+		t.Run(file, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(file, []byte(content), 0o644))
+			defer func() {
+				_ = os.RemoveAll(file)
+			}()
+
+			conf, commandConf, err := loadConfig(Options{Strict: true})
+			require.NoError(t, err)
+			require.Equal(t, nord, conf.ThemeConfig)
+			require.Equal(t, []Command{{
+				Cmd:        "go test -race",
+				WatchPaths: []string{pwd},
+				Env:        map[string]string{"GOFLAGS": "-mod=mod"},
+				Shell:      "sh",
+			}}, commandConf.Commands)
+		})
+	}
+}
+
+func TestValidateTOMLPositions(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	file := "./panopticon.toml"
+	require.NoError(t, os.WriteFile(file, []byte("[[commands]]\ncmd = \"go build\"\nwatch_path = [\"./\"]\n"), 0o644))
+	defer func() {
+		_ = os.RemoveAll(file)
+	}()
+
+	diagnostics, err := Validate()
+	require.NoError(t, err)
+	require.Len(t, diagnostics, 2)
+	require.Equal(t, `panopticon.toml:1:3: commands[0]: missing required field "watch_paths"`, diagnostics[0].String())
+	require.Equal(t, `panopticon.toml:3:1: unknown field "watch_path", did you mean "watch_paths"?`, diagnostics[1].String())
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	userDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", userDir)
+	require.NoError(t, os.MkdirAll(userDir+"/panopticon", 0o755))
+	userConfig := `defaults:
+  shell: zsh
+  debounce: 1s
+  ignore_patterns: ['*.swp']
+  watch_paths: ['./']
+keys:
+  run: [ctrl+r]
+  quit: [ctrl+q]
+commands:
+  - name: notes
+    cmd: echo notes
+`
+	require.NoError(t, os.WriteFile(userDir+"/panopticon/config.yaml", []byte(userConfig), 0o644))
+
+	projectConfig := `defaults:
+  shell: bash
+keys:
+  run: [R]
+commands:
+  - cmd: go build
+  - cmd: go test
+    debounce: 50ms
+`
+	require.NoError(t, os.WriteFile(commandFile, []byte(projectConfig), 0o644))
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+	}()
+
+	conf, commandConf, err := loadConfig(Options{Strict: true})
+	require.NoError(t, err)
+
+	var got []string
+	for _, cmd := range commandConf.Commands {
+		got = append(got, fmt.Sprintf("%s %s %s %v", cmd.title(), cmd.Shell, cmd.Debounce, cmd.IgnorePatterns))
+	}
+	require.Equal(t, []string{
+		"go build bash 1s [*.swp]",
+		"go test bash 50ms [*.swp]",
+		"notes bash 1s [*.swp]",
+	}, got)
+
+	require.Equal(t, []string{"R"}, conf.Keys.Run)
+	require.Equal(t, []string{"ctrl+q"}, conf.Keys.Quit)
+	require.Equal(t, defaultKeys.Toggle, conf.Keys.Toggle)
+
+	_, commandConf, err = loadConfig(Options{Shell: "fish", Debounce: time.Second})
+	require.NoError(t, err)
+	require.Equal(t, "fish", commandConf.Commands[1].Shell)
+	require.Equal(t, time.Second, commandConf.Commands[1].Debounce)
+}
@@ -0,0 +1,23 @@
+package internal
+
+import (
+	_ "embed"
+	"html/template"
+	"log"
+	"net/http"
+)
+
+//go:embed dashboard.html
+var dashboardHTML string
+
+// dashboardTemplate is a self-contained page mirroring the TUI, colored with
+// the theme, that follows the event stream and runs and cancels commands
+// through the API.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(dashboardHTML))
+
+func (srv *Server) dashboard(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := dashboardTemplate.Execute(w, srv.m.theme); err != nil {
+		log.Println("Error rendering dashboard:", err)
+	}
+}
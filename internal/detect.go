@@ -0,0 +1,298 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// longest comment shown for a proposal, such as a package.json script's body
+const maxCommentWidth = 72
+
+// toolchain is a set of commands proposed for a project type found in a directory.
+type toolchain struct {
+	name     string
+	file     string
+	commands []proposal
+}
+
+type proposal struct {
+	cmd      Command
+	comment  string
+	selected bool
+}
+
+var (
+	makeTargetPattern = regexp.MustCompile(`^([A-Za-z0-9][A-Za-z0-9_.-]*)\s*:([^=]|$)`)
+	justRecipePattern = regexp.MustCompile(`^@?([A-Za-z0-9][A-Za-z0-9_-]*)(\s+[^:]*)?:([^=]|$)`)
+	// targets and scripts usually cheap enough to run on every change
+	defaultTasks = map[string]bool{
+		"build":     true,
+		"test":      true,
+		"lint":      true,
+		"check":     true,
+		"typecheck": true,
+	}
+)
+
+type detector func(dir string) (toolchain, bool)
+
+var detectors = []detector{
+	detectGo,
+	detectNode,
+	detectRust,
+	detectPython,
+	detectMake,
+	detectJust,
+}
+
+// detectToolchains inspects dir for known project files and proposes commands for each.
+func detectToolchains(dir string) []toolchain {
+	var found []toolchain
+	for _, detect := range detectors {
+		if tc, ok := detect(dir); ok && len(tc.commands) > 0 {
+			found = append(found, tc)
+		}
+	}
+	return found
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// existingPaths returns the candidates present in dir, relative to it.
+func existingPaths(dir string, candidates ...string) []string {
+	var paths []string
+	for _, c := range candidates {
+		if exists(filepath.Join(dir, c)) {
+			paths = append(paths, c)
+		}
+	}
+	return paths
+}
+
+// watchPaths returns the candidates present in dir as watch paths,
+// falling back to the whole directory.
+func watchPaths(dir string, candidates ...string) []string {
+	paths := existingPaths(dir, candidates...)
+	if len(paths) == 0 {
+		return []string{"./"}
+	}
+	for i, p := range paths {
+		paths[i] = "./" + p
+	}
+	return paths
+}
+
+// newProposal proposes cmd, with comment collapsed onto one line to be
+// written after a # in panopticon.yaml.
+func newProposal(cmd string, watch, ignore []string, comment string, selected bool) proposal {
+	comment = ansi.Truncate(strings.Join(strings.Fields(comment), " "), maxCommentWidth, "…")
+	return proposal{
+		cmd:      Command{Cmd: cmd, WatchPaths: watch, IgnorePaths: ignore},
+		comment:  comment,
+		selected: selected,
+	}
+}
+
+func detectGo(dir string) (toolchain, bool) {
+	if !exists(filepath.Join(dir, "go.mod")) {
+		return toolchain{}, false
+	}
+
+	watch := []string{"./"}
+	ignore := existingPaths(dir, ".git", "vendor")
+	return toolchain{
+		name: "Go",
+		file: "go.mod",
+		commands: []proposal{
+			newProposal("go build ./...", watch, ignore, "compile all packages", true),
+			newProposal("go test ./...", watch, ignore, "run all tests", true),
+			newProposal("go vet ./...", watch, ignore, "report suspicious constructs", false),
+		},
+	}, true
+}
+
+// packageScripts returns the scripts defined in a package.json.
+func packageScripts(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var pkg struct {
+		Scripts map[string]string `json:"scripts"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return nil, err
+	}
+	return pkg.Scripts, nil
+}
+
+// packageManager guesses the package manager used in dir from its lockfile.
+func packageManager(dir string) string {
+	switch {
+	case exists(filepath.Join(dir, "pnpm-lock.yaml")):
+		return "pnpm"
+	case exists(filepath.Join(dir, "yarn.lock")):
+		return "yarn"
+	case exists(filepath.Join(dir, "bun.lockb")), exists(filepath.Join(dir, "bun.lock")):
+		return "bun"
+	default:
+		return "npm"
+	}
+}
+
+func detectNode(dir string) (toolchain, bool) {
+	scripts, err := packageScripts(filepath.Join(dir, "package.json"))
+	if err != nil {
+		return toolchain{}, false
+	}
+
+	watch := watchPaths(dir, "src", "lib", "test", "tests")
+	ignore := existingPaths(dir, ".git", "node_modules", "dist", "build", "coverage")
+	pm := packageManager(dir)
+
+	tc := toolchain{name: "Node", file: "package.json"}
+	for _, name := range sortedKeys(scripts) {
+		if isLifecycleScript(name, scripts) {
+			continue
+		}
+		tc.commands = append(tc.commands, newProposal(pm+" run "+name, watch, ignore, scripts[name], defaultTasks[name]))
+	}
+	return tc, true
+}
+
+// isLifecycleScript reports whether name is a pre or post hook of another
+// script, which the package manager runs as part of that script.
+func isLifecycleScript(name string, scripts map[string]string) bool {
+	for _, prefix := range []string{"pre", "post"} {
+		if base, ok := strings.CutPrefix(name, prefix); ok {
+			if _, hooked := scripts[base]; hooked {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func detectRust(dir string) (toolchain, bool) {
+	if !exists(filepath.Join(dir, "Cargo.toml")) {
+		return toolchain{}, false
+	}
+
+	watch := watchPaths(dir, "src", "tests", "benches", "examples")
+	ignore := existingPaths(dir, ".git", "target")
+	return toolchain{
+		name: "Rust",
+		file: "Cargo.toml",
+		commands: []proposal{
+			newProposal("cargo build", watch, ignore, "compile the crate", true),
+			newProposal("cargo test", watch, ignore, "run all tests", true),
+			newProposal("cargo clippy", watch, ignore, "lint with clippy", false),
+		},
+	}, true
+}
+
+func detectPython(dir string) (toolchain, bool) {
+	data, err := os.ReadFile(filepath.Join(dir, "pyproject.toml"))
+	if err != nil {
+		return toolchain{}, false
+	}
+	pyproject := string(data)
+
+	watch := watchPaths(dir, "src", "tests")
+	ignore := existingPaths(dir, ".git", ".venv", "venv", ".pytest_cache", ".mypy_cache", ".ruff_cache")
+
+	tc := toolchain{name: "Python", file: "pyproject.toml"}
+	tc.commands = append(tc.commands, newProposal("python -m pytest", watch, ignore, "run tests with pytest", true))
+	if strings.Contains(pyproject, "ruff") {
+		tc.commands = append(tc.commands, newProposal("ruff check .", watch, ignore, "lint with ruff", true))
+	}
+	if strings.Contains(pyproject, "mypy") {
+		tc.commands = append(tc.commands, newProposal("mypy .", watch, ignore, "type check with mypy", false))
+	}
+	return tc, true
+}
+
+// makeTargets returns the explicit targets of a Makefile, in order.
+func makeTargets(path string) ([]string, error) {
+	return scanNames(path, makeTargetPattern)
+}
+
+// justRecipes returns the recipes of a justfile, in order.
+func justRecipes(path string) ([]string, error) {
+	return scanNames(path, justRecipePattern)
+}
+
+func scanNames(path string, pattern *regexp.Regexp) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := pattern.FindStringSubmatch(scanner.Text())
+		if m == nil || seen[m[1]] {
+			continue
+		}
+		seen[m[1]] = true
+		names = append(names, m[1])
+	}
+	return names, scanner.Err()
+}
+
+func detectMake(dir string) (toolchain, bool) {
+	for _, file := range []string{"GNUmakefile", "makefile", "Makefile"} {
+		targets, err := makeTargets(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+
+		ignore := existingPaths(dir, ".git")
+		tc := toolchain{name: "Make", file: file}
+		for _, target := range targets {
+			tc.commands = append(tc.commands, newProposal("make "+target, []string{"./"}, ignore, "", defaultTasks[target]))
+		}
+		return tc, true
+	}
+	return toolchain{}, false
+}
+
+func detectJust(dir string) (toolchain, bool) {
+	for _, file := range []string{"justfile", "Justfile", ".justfile"} {
+		recipes, err := justRecipes(filepath.Join(dir, file))
+		if err != nil {
+			continue
+		}
+
+		ignore := existingPaths(dir, ".git")
+		tc := toolchain{name: "just", file: file}
+		for _, recipe := range recipes {
+			tc.commands = append(tc.commands, newProposal("just "+recipe, []string{"./"}, ignore, "", defaultTasks[recipe]))
+		}
+		return tc, true
+	}
+	return toolchain{}, false
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestDetectToolchains(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"go.mod":       "module example.com/foo\n",
+		"package.json": `{"scripts": {"build": "tsc", "pretest": "rm -rf out", "test": "jest", "prettier": "prettier ."}}`,
+		"yarn.lock":    "",
+		"src/index.ts": "",
+		"Makefile":     "VERSION := 1\n.PHONY: build\nbuild: deps\n\tgo build\n%.o: %.c\n\tcc\nrelease:\n\tgoreleaser\n",
+		"justfile":     "set shell := [\"bash\", \"-c\"]\ntest filter=\"\":\n  go test -run {{filter}}\n@lint:\n  golangci-lint run\n",
+	})
+
+	toolchains := detectToolchains(dir)
+
+	var names []string
+	cmds := map[string][]string{}
+	for _, tc := range toolchains {
+		names = append(names, tc.name)
+		for _, p := range tc.commands {
+			cmds[tc.name] = append(cmds[tc.name], p.cmd.Cmd)
+		}
+	}
+
+	require.Equal(t, []string{"Go", "Node", "Make", "just"}, names)
+	require.Equal(t, []string{"yarn run build", "yarn run prettier", "yarn run test"}, cmds["Node"])
+	require.Equal(t, []string{"make build", "make release"}, cmds["Make"])
+	require.Equal(t, []string{"just test", "just lint"}, cmds["just"])
+	require.Equal(t, []string{"./src"}, toolchains[1].commands[0].cmd.WatchPaths)
+}
+
+func TestRenderCommandConfig(t *testing.T) {
+	toolchains := []toolchain{{
+		name: "Go",
+		file: "go.mod",
+		commands: []proposal{
+			newProposal("go build ./...", []string{"./"}, []string{".git"}, "compile all packages", true),
+			newProposal("go vet ./...", []string{"./"}, nil, "", false),
+		},
+	}}
+
+	require.Equal(t, `# yaml-language-server: $schema=panopticon.schema.json
+# Generated by panopticon init for Go (go.mod)
+commands:
+  # Go, from go.mod
+  # compile all packages
+  - cmd: go build ./...
+    watch_paths:
+      - ./
+    ignore_paths:
+      - .git
+
+theme: "default"
+`, renderCommandConfig(toolchains))
+
+	toolchains[0].commands[0].selected = false
+	require.Empty(t, renderCommandConfig(toolchains))
+
+	// package.json scripts can span lines
+	script := "tsc &&\r\n  node dist/check.js " + strings.Repeat("--flag ", 20)
+	toolchains[0].commands[0] = newProposal("npm run check", []string{"./"}, nil, script, true)
+	config := renderCommandConfig(toolchains)
+	require.Contains(t, config, "  # tsc && node dist/check.js --flag")
+	require.Contains(t, config, "…\n  - cmd: npm run check\n")
+	var parsed CommandConfig
+	require.NoError(t, yaml.Unmarshal([]byte(config), &parsed))
+	require.Equal(t, "npm run check", parsed.Commands[0].Cmd)
+}
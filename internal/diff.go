@@ -0,0 +1,180 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+type diffOp int
+
+const (
+	diffEqual diffOp = iota
+	diffDelete
+	diffInsert
+)
+
+type diffLine struct {
+	op   diffOp
+	text string
+}
+
+// past this many edits, lines in between the common start and end are shown
+// as all removed then all added, rather than diffing them line by line. The
+// trace kept to walk back through grows with its square.
+const maxDiffEdits = 1000
+
+// diffLines returns the line edits turning a into b, using Myers' algorithm.
+func diffLines(a, b []string) []diffLine {
+	var prefix, suffix int
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var lines []diffLine
+	for _, line := range a[:prefix] {
+		lines = append(lines, diffLine{diffEqual, line})
+	}
+	lines = append(lines, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, line := range a[len(a)-suffix:] {
+		lines = append(lines, diffLine{diffEqual, line})
+	}
+	return lines
+}
+
+func myers(a, b []string) []diffLine {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxDiffEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	// the diagonals -d to d of v before each number of edits d, to walk back
+	// through
+	var trace [][]int
+	for d := 0; d <= limit; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace, d)
+			}
+		}
+	}
+
+	var lines []diffLine
+	for _, line := range a {
+		lines = append(lines, diffLine{diffDelete, line})
+	}
+	for _, line := range b {
+		lines = append(lines, diffLine{diffInsert, line})
+	}
+	return lines
+}
+
+func backtrack(a, b []string, trace [][]int, d int) []diffLine {
+	var lines []diffLine
+	x, y := len(a), len(b)
+	for ; d > 0; d-- {
+		v := trace[d]
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[d+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			lines = append(lines, diffLine{diffEqual, a[x]})
+		}
+		if x == prevX {
+			y--
+			lines = append(lines, diffLine{diffInsert, b[y]})
+		} else {
+			x--
+			lines = append(lines, diffLine{diffDelete, a[x]})
+		}
+	}
+	for x > 0 {
+		x--
+		lines = append(lines, diffLine{diffEqual, a[x]})
+	}
+
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+	return lines
+}
+
+// outputLines splits output into lines for diffing, without colors.
+func outputLines(output string) []string {
+	output = strings.TrimSuffix(ansi.Strip(output), "\n")
+	if output == "" {
+		return nil
+	}
+	return strings.Split(output, "\n")
+}
+
+// newDiffPager shows the changes from one run's output to another's in the pager.
+func newDiffPager(from, to pastRun, theme Theme, width, height int) *pager {
+	lines := diffLines(outputLines(from.res.output), outputLines(to.res.output))
+
+	var added, removed int
+	var b strings.Builder
+	for _, l := range lines {
+		switch l.op {
+		case diffInsert:
+			added++
+			b.WriteString("+ ")
+		case diffDelete:
+			removed++
+			b.WriteString("- ")
+		default:
+			b.WriteString("  ")
+		}
+		b.WriteString(l.text + "\n")
+	}
+
+	summary := fmt.Sprintf("%s %s → %s %s: %d added, %d removed\n",
+		getEmoji(from.res.status), fmt.Sprintf("#%d", from.number), getEmoji(to.res.status), fmt.Sprintf("#%d", to.number), added, removed)
+	if added == 0 && removed == 0 {
+		summary += "Outputs are identical\n"
+	}
+
+	title := fmt.Sprintf("%s: diff #%d → #%d", to.res.job.title(), from.number, to.number)
+	p := newPager(-1, title, summary+b.String(), theme, width, height)
+
+	addedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.added()))
+	removedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color(theme.removed()))
+	p.lineStyle = func(line string) lipgloss.Style {
+		switch {
+		case strings.HasPrefix(line, "+ "):
+			return addedStyle
+		case strings.HasPrefix(line, "- "):
+			return removedStyle
+		default:
+			return lipgloss.NewStyle()
+		}
+	}
+	return p
+}
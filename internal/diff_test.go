@@ -0,0 +1,78 @@
+package internal
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffLines(t *testing.T) {
+	render := func(lines []diffLine) string {
+		var b strings.Builder
+		for _, l := range lines {
+			b.WriteString([]string{" ", "-", "+"}[l.op] + l.text + "\n")
+		}
+		return b.String()
+	}
+
+	a := strings.Split("ok a\nFAIL b\nok c\nFAIL d\nok e", "\n")
+	b := strings.Split("ok a\nok b\nok c\nFAIL d\nFAIL e\nFAIL f", "\n")
+	require.Equal(t, " ok a\n-FAIL b\n+ok b\n ok c\n FAIL d\n-ok e\n+FAIL e\n+FAIL f\n", render(diffLines(a, b)))
+
+	require.Equal(t, "+x\n", render(diffLines(nil, []string{"x"})))
+	require.Empty(t, diffLines(nil, nil))
+
+	// applying the edits to a gives b, for a less tidy pair too
+	a = strings.Split("a b c a b b a", " ")
+	b = strings.Split("c b a b a c", " ")
+	var got []string
+	for _, l := range diffLines(a, b) {
+		if l.op != diffDelete {
+			got = append(got, l.text)
+		}
+	}
+	require.Equal(t, b, got)
+
+	// and gives back both sides of a longer pair
+	a, b = nil, nil
+	for i := range 500 {
+		a = append(a, strconv.Itoa(i%7))
+		b = append(b, strconv.Itoa(i%5))
+	}
+	var fromA, toB []string
+	for _, l := range diffLines(a, b) {
+		if l.op != diffInsert {
+			fromA = append(fromA, l.text)
+		}
+		if l.op != diffDelete {
+			toB = append(toB, l.text)
+		}
+	}
+	require.Equal(t, a, fromA)
+	require.Equal(t, b, toB)
+
+	// past maxDiffEdits, everything in between is replaced
+	a, b = nil, nil
+	for i := range maxDiffEdits {
+		a = append(a, "a"+strconv.Itoa(i))
+		b = append(b, "b"+strconv.Itoa(i))
+	}
+	lines := diffLines(a, b)
+	require.Len(t, lines, 2*maxDiffEdits)
+	require.Equal(t, diffLine{diffDelete, "a0"}, lines[0])
+	require.Equal(t, diffLine{diffInsert, "b0"}, lines[maxDiffEdits])
+}
+
+func TestDiffPager(t *testing.T) {
+	from := pastRun{1, result{status: Failed, job: Command{Name: "test"}, output: "--- FAIL: TestA\nok\n"}}
+	to := pastRun{2, result{status: Failed, job: Command{Name: "test"}, output: "--- FAIL: TestA\n--- FAIL: TestB\nok\n"}}
+
+	p := newDiffPager(from, to, catppuccin, 80, 10)
+	view := p.view()
+	require.Contains(t, view, "test: diff #1 → #2")
+	require.Contains(t, view, "1 added, 0 removed")
+	require.Contains(t, view, "+ --- FAIL: TestB")
+	require.Contains(t, view, "  --- FAIL: TestA")
+}
@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// eventsVersion is bumped whenever an event's fields change incompatibly.
+const eventsVersion = 1
+
+// event types
+const (
+	eventConfigLoaded = "config_loaded"
+	eventWatch        = "watch"
+	eventChange       = "change"
+	eventRunStarted   = "run_started"
+	eventOutput       = "output"
+	eventRunFinished  = "run_finished"
+)
+
+var eventTypes = []string{eventConfigLoaded, eventWatch, eventChange, eventRunStarted, eventOutput, eventRunFinished}
+
+// Event is one line of the JSON event stream.
+type Event struct {
+	Version    int            `json:"version" desc:"Version of the event schema"`
+	Seq        int            `json:"seq" desc:"Position of the event in the stream, starting at 1"`
+	Time       time.Time      `json:"time" desc:"When the event happened"`
+	Type       string         `json:"type" enum:"event" desc:"What happened"`
+	Command    *EventCommand  `json:"command,omitempty" desc:"The command the event is about, on all but config_loaded"`
+	Commands   []EventCommand `json:"commands,omitempty" desc:"config_loaded: the commands being run"`
+	Paths      []string       `json:"paths,omitempty" desc:"watch: directories the command's watcher registered"`
+	File       string         `json:"file,omitempty" desc:"change: the file that changed, relative to the working directory if inside it"`
+	Trigger    string         `json:"trigger,omitempty" desc:"run_started: what started the run: start, manual or change"`
+	Files      []string       `json:"files,omitempty" desc:"run_started: files whose changes started the run"`
+	Output     string         `json:"output,omitempty" desc:"output: a chunk of stdout or stderr as it arrived; run_finished: the full output"`
+	Status     string         `json:"status,omitempty" desc:"run_finished: succeeded or failed"`
+	ExitCode   *int           `json:"exit_code,omitempty" desc:"run_finished: the command's exit code, or -1 if it didn't start, was killed or was canceled"`
+	DurationMs int64          `json:"duration_ms,omitempty" desc:"run_finished: how long the run took in milliseconds"`
+}
+
+// EventCommand identifies a command in an event.
+type EventCommand struct {
+	ID   int    `json:"id" desc:"Position of the command in the config"`
+	Name string `json:"name" desc:"The command's name, or its cmd if it has none"`
+	Cmd  string `json:"cmd" desc:"The shell command run"`
+}
+
+// EventStream turns everything panopticon does into numbered events.
+type EventStream struct {
+	mu       sync.Mutex
+	publish  func(Event)
+	seq      int
+	commands []EventCommand
+	// the latest run of each command
+	runs map[int]result
+}
+
+// NewEventStream writes the model's events to w as newline-delimited JSON,
+// starting with config_loaded.
+func NewEventStream(m model, w io.Writer) *EventStream {
+	enc := json.NewEncoder(w)
+	return newEventStream(m, func(e Event) {
+		if err := enc.Encode(e); err != nil {
+			log.Println("Error writing event:", err)
+		}
+	})
+}
+
+// newEventStream passes the model's events to publish in order, starting
+// with config_loaded.
+func newEventStream(m model, publish func(Event)) *EventStream {
+	commands := make([]EventCommand, len(m.commands))
+	for i, cmd := range m.commands {
+		commands[i] = eventCommand(cmd)
+	}
+
+	s := &EventStream{publish: publish, commands: commands, runs: make(map[int]result)}
+	s.emit(Event{Type: eventConfigLoaded, Commands: commands})
+	return s
+}
+
+func eventCommand(cmd Command) EventCommand {
+	return EventCommand{ID: cmd.ID, Name: cmd.title(), Cmd: cmd.Cmd}
+}
+
+// Send writes a message from a watcher or running command as an event.
+func (s *EventStream) Send(msg tea.Msg) {
+	switch msg := msg.(type) {
+	case watchMsg:
+		s.emit(Event{Type: eventWatch, Command: s.command(msg.id), Paths: msg.paths})
+	case changeMsg:
+		s.emit(Event{Type: eventChange, Command: s.command(msg.id), File: msg.file})
+	case outputMsg:
+		if !msg.of(s.run(msg.id)) {
+			return
+		}
+		s.emit(Event{Type: eventOutput, Command: s.command(msg.id), Output: msg.chunk})
+	case result:
+		s.mu.Lock()
+		s.runs[msg.job.ID] = msg
+		s.mu.Unlock()
+		if msg.status == Pending {
+			s.emit(Event{
+				Type:    eventRunStarted,
+				Command: s.command(msg.job.ID),
+				Trigger: msg.trigger.reason,
+				Files:   msg.trigger.files,
+			})
+			return
+		}
+		e := runFinishedEvent(msg)
+		e.Command = s.command(msg.job.ID)
+		s.emit(e)
+	}
+}
+
+// runFinishedEvent describes a finished run, without the fields set when
+// it's emitted.
+func runFinishedEvent(res result) Event {
+	command := eventCommand(res.job)
+	exitCode := res.exitCode
+	return Event{
+		Type:       eventRunFinished,
+		Command:    &command,
+		Output:     res.output,
+		Status:     strings.ToLower(res.status.String()),
+		ExitCode:   &exitCode,
+		DurationMs: res.duration.Milliseconds(),
+	}
+}
+
+func (s *EventStream) run(id int) result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.runs[id]
+}
+
+func (s *EventStream) command(id int) *EventCommand {
+	if id < 0 || id >= len(s.commands) {
+		return nil
+	}
+	return &s.commands[id]
+}
+
+func (s *EventStream) emit(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	e.Version = eventsVersion
+	e.Seq = s.seq
+	e.Time = time.Now()
+	s.publish(e)
+}
@@ -0,0 +1,62 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventStream(t *testing.T) {
+	commands := []Command{
+		{ID: 0, Name: "ok", Cmd: "echo fine", Shell: "sh"},
+		{ID: 1, Name: "bad", Cmd: "echo broken >&2; exit 3", Shell: "sh"},
+	}
+	m := model{commands: commands, theme: catppuccin}
+	var out, events bytes.Buffer
+	require.Equal(t, 1, RunOnce(m, &out, context.Background(), 1, NewEventStream(m, &events)))
+
+	var got []Event
+	for _, line := range strings.Split(strings.TrimSpace(events.String()), "\n") {
+		var e Event
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		require.Equal(t, eventsVersion, e.Version)
+		require.Equal(t, len(got)+1, e.Seq)
+		got = append(got, e)
+	}
+
+	require.Equal(t, eventConfigLoaded, got[0].Type)
+	require.Equal(t, []EventCommand{{0, "ok", "echo fine"}, {1, "bad", "echo broken >&2; exit 3"}}, got[0].Commands)
+
+	finished := make(map[string]Event)
+	var output string
+	for _, e := range got[1:] {
+		switch e.Type {
+		case eventRunStarted:
+			require.Equal(t, triggerStart, e.Trigger)
+		case eventOutput:
+			if e.Command.Name == "bad" {
+				output += e.Output
+			}
+		case eventRunFinished:
+			finished[e.Command.Name] = e
+		}
+	}
+	require.Equal(t, "broken\n", output)
+
+	require.Equal(t, "succeeded", finished["ok"].Status)
+	require.Equal(t, 0, *finished["ok"].ExitCode)
+	require.Equal(t, "failed", finished["bad"].Status)
+	require.Equal(t, 3, *finished["bad"].ExitCode)
+
+	// a run watched for changes
+	events.Reset()
+	s := NewEventStream(m, &events)
+	s.Send(watchMsg{0, []string{"internal"}})
+	s.Send(changeMsg{0, "internal/main.go"})
+	require.Contains(t, events.String(), `"type":"watch","command":{"id":0,"name":"ok","cmd":"echo fine"},"paths":["internal"]}`)
+	require.Contains(t, events.String(), `"type":"change","command":{"id":0,"name":"ok","cmd":"echo fine"},"file":"internal/main.go"}`)
+}
@@ -10,17 +10,32 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/fsnotify/fsnotify"
+	"github.com/gobwas/glob"
 )
 
-func WatchForChanges(m model, p *tea.Program, ctx context.Context) []*fsnotify.Watcher {
+// watchMsg reports the paths a command's watcher registered.
+type watchMsg struct {
+	id    int
+	paths []string
+}
+
+// changeMsg reports a file change that will trigger a command.
+type changeMsg struct {
+	id   int
+	file string
+}
+
+func WatchForChanges(m model, p Sender, ctx context.Context) []*fsnotify.Watcher {
 	var watchers []*fsnotify.Watcher
 	for _, cmd := range m.commands {
-		watchers = append(watchers, watchForChange(cmd, p, ctx))
+		watchers = append(watchers, watchForChange(m, cmd, p, ctx))
 	}
 	return watchers
 }
 
-func watchForChange(command Command, p *tea.Program, ctx context.Context) *fsnotify.Watcher {
+// watchForChange runs command when its watch paths change, skipping files
+// panopticon writes itself and changes while watching is paused.
+func watchForChange(m model, command Command, p Sender, ctx context.Context) *fsnotify.Watcher {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		log.Fatal(err)
@@ -29,16 +44,37 @@ func watchForChange(command Command, p *tea.Program, ctx context.Context) *fsnot
 	paths := getPaths(command)
 
 	// Add all paths to single watcher
+	var watching []string
 	for _, subdir := range paths {
 		err = watcher.Add(subdir)
 		log.Println("Watching:", subdir)
 		if err != nil {
 			log.Println("Error watching:", subdir, err)
+			continue
 		}
+		watching = append(watching, subdir)
 	}
+	p.Send(watchMsg{command.ID, watching})
 
 	// Use a cancellable context for command execution
 	cmdCtx, cancelCmd := context.WithCancel(ctx)
+	// files changed since the last run
+	changed := make(map[string]bool)
+	run := func() {
+		// Cancel previous command and start new one
+		cancelCmd()
+		cmdCtx, cancelCmd = context.WithCancel(ctx)
+
+		t := trigger{reason: triggerChange, files: sortedKeys(changed)}
+		clear(changed)
+		go m.runTracked(command, t, p, cmdCtx)
+	}
+
+	ignore := ignoreMatchers(command.IgnorePatterns)
+
+	// Changes within the debounce window are coalesced into one run
+	var debounce *time.Timer
+	settled := make(chan struct{}, 1)
 
 	// Only one goroutine per watcher
 	go func() {
@@ -48,18 +84,38 @@ func watchForChange(command Command, p *tea.Program, ctx context.Context) *fsnot
 			select {
 			case <-ctx.Done():
 				cancelCmd() // Cancel any running command
+				if debounce != nil {
+					debounce.Stop()
+				}
 				return
 			case event, ok := <-watcher.Events:
 				if !ok {
 					return
 				}
-				if event.Has(fsnotify.Write) && !strings.Contains(event.Name, "pan.log") {
-					// Cancel previous command and start new one
-					cancelCmd()
-					cmdCtx, cancelCmd = context.WithCancel(ctx)
-
-					go runProcess(command, p, cmdCtx)
+				if !event.Has(fsnotify.Write) || strings.Contains(event.Name, "pan.log") || m.isOwnFile(event.Name) || isIgnored(event.Name, ignore) {
+					continue
+				}
+				if m.control.isPaused() {
+					continue
+				}
+				file := relativePath(event.Name)
+				changed[file] = true
+				p.Send(changeMsg{command.ID, file})
+				if command.Debounce <= 0 {
+					run()
+					continue
 				}
+				if debounce != nil {
+					debounce.Stop()
+				}
+				debounce = time.AfterFunc(command.Debounce, func() {
+					select {
+					case settled <- struct{}{}:
+					default:
+					}
+				})
+			case <-settled:
+				run()
 			case err, ok := <-watcher.Errors:
 				if !ok {
 					return
@@ -72,6 +128,52 @@ func watchForChange(command Command, p *tea.Program, ctx context.Context) *fsnot
 	return watcher
 }
 
+// ignoreMatchers compiles ignore patterns, skipping any that are invalid.
+func ignoreMatchers(patterns []string) []glob.Glob {
+	var matchers []glob.Glob
+	for _, pattern := range patterns {
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			log.Println("Invalid ignore pattern:", pattern, err)
+			continue
+		}
+		matchers = append(matchers, g)
+	}
+	return matchers
+}
+
+// isIgnored reports whether any directory or file along path, relative to
+// the working directory, matches an ignore pattern by name or relative path.
+func isIgnored(path string, matchers []glob.Glob) bool {
+	if len(matchers) == 0 {
+		return false
+	}
+
+	parts := strings.Split(filepath.ToSlash(relativePath(path)), "/")
+	for i, part := range parts {
+		prefix := strings.Join(parts[:i+1], "/")
+		for _, g := range matchers {
+			if g.Match(part) || g.Match(prefix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// relativePath returns path relative to the working directory if it's
+// inside it, or path unchanged.
+func relativePath(path string) string {
+	if abs, err := getAbsolutePath(path); err == nil {
+		if pwd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(pwd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+				return rel
+			}
+		}
+	}
+	return path
+}
+
 func getPaths(command Command) []string {
 	var paths []string
 	paths = append(paths, command.WatchPaths...)
@@ -89,6 +191,8 @@ func getPaths(command Command) []string {
 		paths = append(paths, subdirs...)
 	}
 
+	ignore := ignoreMatchers(command.IgnorePatterns)
+
 	// remove ignored
 	var filteredPaths []string
 	for _, path := range paths {
@@ -100,7 +204,7 @@ func getPaths(command Command) []string {
 				shouldIgnore = true
 			}
 		}
-		if !shouldIgnore {
+		if !shouldIgnore && !isIgnored(path, ignore) {
 			filteredPaths = append(filteredPaths, path)
 		}
 	}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2/unstable"
+	"gopkg.in/yaml.v3"
+)
+
+// config file extensions in order of preference
+var configExtensions = []string{".yaml", ".toml", ".json"}
+
+// findConfigFile returns the first existing file of base with a supported
+// extension, or base.yaml if there are none.
+func findConfigFile(base string) (string, error) {
+	var found []string
+	for _, ext := range configExtensions {
+		if _, err := os.Stat(base + ext); err == nil {
+			found = append(found, base+ext)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return base + configExtensions[0], fmt.Errorf("no %s.yaml, .toml or .json found: %w", filepath.Base(base), os.ErrNotExist)
+	case 1:
+		return found[0], nil
+	default:
+		names := make([]string, len(found))
+		for i, f := range found {
+			names[i] = filepath.Base(f)
+		}
+		return found[0], fmt.Errorf("found more than one config file (%s), remove all but one", strings.Join(names, ", "))
+	}
+}
+
+// projectRoot is the nearest directory with a panopticon config, from the
+// working directory up, relative to the working directory. It's the working
+// directory if none has one.
+func projectRoot() string {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "."
+	}
+	for up := "."; ; up = filepath.Join(up, "..") {
+		if _, err := findConfigFile(filepath.Join(up, commandFileBase)); !errors.Is(err, os.ErrNotExist) {
+			return up
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "."
+		}
+		dir = parent
+	}
+}
+
+// parseNode reads data into a yaml node tree according to the file's extension,
+// keeping positions so every format gets the same diagnostics. JSON is a
+// subset of YAML, so only TOML needs converting.
+func parseNode(file string, data []byte) (*yaml.Node, error) {
+	var root yaml.Node
+	switch filepath.Ext(file) {
+	case ".toml":
+		doc, err := tomlNode(file, data)
+		if err != nil {
+			return nil, err
+		}
+		root = yaml.Node{Kind: yaml.DocumentNode, Line: 1, Column: 1, Content: []*yaml.Node{doc}}
+	default:
+		if err := yaml.Unmarshal(data, &root); err != nil {
+			return nil, &ConfigError{[]Diagnostic{errorDiagnostic(file, err.Error())}}
+		}
+	}
+	return &root, nil
+}
+
+// tomlNode converts a TOML document to the equivalent yaml mapping node.
+func tomlNode(file string, data []byte) (*yaml.Node, error) {
+	p := unstable.Parser{}
+	p.Reset(data)
+
+	root := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: 1, Column: 1}
+	current := root
+	for p.NextExpression() {
+		expr := p.Expression()
+		switch expr.Kind {
+		case unstable.KeyValue:
+			table, key := tomlTable(&p, current, expr.Key())
+			value := tomlValue(&p, expr.Value(), key)
+			table.Content = append(table.Content, key, value)
+		case unstable.Table:
+			parent, key := tomlTable(&p, root, expr.Key())
+			current = tomlChild(parent, key, yaml.MappingNode)
+			if current.Kind == yaml.SequenceNode && len(current.Content) > 0 {
+				current = current.Content[len(current.Content)-1]
+			}
+		case unstable.ArrayTable:
+			parent, key := tomlTable(&p, root, expr.Key())
+			seq := tomlChild(parent, key, yaml.SequenceNode)
+			current = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", Line: key.Line, Column: key.Column}
+			seq.Content = append(seq.Content, current)
+		}
+	}
+
+	if err := p.Error(); err != nil {
+		d := Diagnostic{File: filepath.Clean(file), Message: err.Error()}
+		var perr *unstable.ParserError
+		if errors.As(err, &perr) {
+			pos := p.Shape(p.Range(perr.Highlight)).Start
+			d.Line, d.Column, d.Message = pos.Line, pos.Column, perr.Message
+		}
+		return nil, &ConfigError{[]Diagnostic{d}}
+	}
+	return root, nil
+}
+
+// tomlTable walks a dotted key from table, creating intermediate tables as
+// needed, and returns the table holding the last part along with its key node.
+func tomlTable(p *unstable.Parser, table *yaml.Node, parts unstable.Iterator) (*yaml.Node, *yaml.Node) {
+	var key *yaml.Node
+	for parts.Next() {
+		if key != nil {
+			table = tomlChild(table, key, yaml.MappingNode)
+			// a [table] after [[array]] refers to the array's last element
+			if table.Kind == yaml.SequenceNode && len(table.Content) > 0 {
+				table = table.Content[len(table.Content)-1]
+			}
+		}
+		part := parts.Node()
+		key = tomlScalar(p, part, "!!str", string(part.Data), nil)
+	}
+	return table, key
+}
+
+// tomlChild returns the value of key in table, adding an empty node of kind if missing.
+func tomlChild(table, key *yaml.Node, kind yaml.Kind) *yaml.Node {
+	for i := 0; i+1 < len(table.Content); i += 2 {
+		if table.Content[i].Value == key.Value {
+			return table.Content[i+1]
+		}
+	}
+	tag := "!!map"
+	if kind == yaml.SequenceNode {
+		tag = "!!seq"
+	}
+	child := &yaml.Node{Kind: kind, Tag: tag, Line: key.Line, Column: key.Column}
+	table.Content = append(table.Content, key, child)
+	return child
+}
+
+func tomlValue(p *unstable.Parser, n *unstable.Node, key *yaml.Node) *yaml.Node {
+	switch n.Kind {
+	case unstable.Array:
+		seq := tomlPosition(p, n, &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}, key)
+		children := n.Children()
+		for children.Next() {
+			seq.Content = append(seq.Content, tomlValue(p, children.Node(), seq))
+		}
+		return seq
+	case unstable.InlineTable:
+		table := tomlPosition(p, n, &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}, key)
+		children := n.Children()
+		for children.Next() {
+			kv := children.Node()
+			parent, childKey := tomlTable(p, table, kv.Key())
+			parent.Content = append(parent.Content, childKey, tomlValue(p, kv.Value(), childKey))
+		}
+		return table
+	case unstable.Bool:
+		return tomlScalar(p, n, "!!bool", string(n.Data), key)
+	case unstable.Integer:
+		return tomlScalar(p, n, "!!int", strings.ReplaceAll(string(n.Data), "_", ""), key)
+	case unstable.Float:
+		value := strings.ReplaceAll(string(n.Data), "_", "")
+		switch strings.TrimLeft(value, "+-") {
+		case "inf":
+			value = strings.Replace(value, "inf", ".inf", 1)
+		case "nan":
+			value = ".nan"
+		}
+		return tomlScalar(p, n, "!!float", value, key)
+	default:
+		// strings, and dates which no config field uses
+		return tomlScalar(p, n, "!!str", string(n.Data), key)
+	}
+}
+
+func tomlScalar(p *unstable.Parser, n *unstable.Node, tag, value string, fallback *yaml.Node) *yaml.Node {
+	return tomlPosition(p, n, &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: value}, fallback)
+}
+
+// tomlPosition sets node's position from n, or from fallback where the
+// parser doesn't record one.
+func tomlPosition(p *unstable.Parser, n *unstable.Node, node, fallback *yaml.Node) *yaml.Node {
+	if n.Raw.Length > 0 {
+		pos := p.Shape(n.Raw).Start
+		node.Line, node.Column = pos.Line, pos.Column
+	} else if fallback != nil {
+		node.Line, node.Column = fallback.Line, fallback.Column
+	}
+	return node
+}
@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Headless prints results and output as timestamped lines prefixed with the
+// command's name, in place of the TUI. Commands run on the same triggers.
+type Headless struct {
+	mu       sync.Mutex
+	out      io.Writer
+	prefixes []string
+	// the latest run of each command
+	runs []result
+	// whether the current run has printed any output
+	streamed []bool
+	// output after the last newline, per command
+	partial map[int]string
+	dim     lipgloss.Style
+}
+
+// NewHeadless prints the model's commands to out.
+func NewHeadless(m model, out io.Writer) *Headless {
+	colors := []string{m.theme.Primary, m.theme.Secondary, m.theme.Tertiary, m.theme.Foreground}
+	var width int
+	for _, cmd := range m.commands {
+		width = max(width, lipgloss.Width(cmd.title()))
+	}
+
+	prefixes := make([]string, len(m.commands))
+	for i, cmd := range m.commands {
+		style := lipgloss.NewStyle().Foreground(lipgloss.Color(colors[i%len(colors)])).Width(width)
+		prefixes[i] = style.Render(cmd.title())
+	}
+
+	return &Headless{
+		out:      out,
+		prefixes: prefixes,
+		runs:     make([]result, len(m.commands)),
+		streamed: make([]bool, len(m.commands)),
+		partial:  make(map[int]string),
+		dim:      lipgloss.NewStyle().Faint(true),
+	}
+}
+
+// RunHeadless runs and watches the model's commands without the TUI, sending
+// results and output to h, until ctx is done.
+func RunHeadless(m model, h Sender, ctx context.Context, runOnStart bool) {
+	if runOnStart {
+		go RunAll(m, h, ctx)
+	}
+	WatchForChanges(m, h, ctx)
+	WatchForTriggers(m, h, ctx)
+
+	<-ctx.Done()
+	// give running commands a moment to be killed
+	time.Sleep(100 * time.Millisecond)
+}
+
+// Send prints a message from a running command.
+func (h *Headless) Send(msg tea.Msg) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case result:
+		id := msg.job.ID
+		h.runs[id] = msg
+		switch msg.status {
+		case Pending:
+			h.partial[id] = ""
+			h.streamed[id] = false
+			h.println(id, h.dim.Render(fmt.Sprintf("started (%s)", msg.trigger)))
+		default:
+			if rest := h.partial[id]; rest != "" {
+				h.println(id, rest)
+			}
+			h.partial[id] = ""
+			// such as failing to start or being canceled
+			if !h.streamed[id] && msg.status == Failed {
+				for _, line := range strings.Split(strings.TrimSpace(msg.output), "\n") {
+					h.println(id, line)
+				}
+			}
+			h.println(id, strings.TrimSpace(getStatus(msg)))
+		}
+	case outputMsg:
+		if !msg.of(h.runs[msg.id]) {
+			return
+		}
+		h.streamed[msg.id] = true
+		lines := strings.Split(h.partial[msg.id]+msg.chunk, "\n")
+		for _, line := range lines[:len(lines)-1] {
+			h.println(msg.id, line)
+		}
+		h.partial[msg.id] = lines[len(lines)-1]
+	}
+}
+
+func (h *Headless) println(id int, line string) {
+	timestamp := h.dim.Render(time.Now().Format(time.TimeOnly))
+	fmt.Fprintf(h.out, "%s %s | %s\n", timestamp, h.prefixes[id], strings.TrimRight(line, "\r"))
+}
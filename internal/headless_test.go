@@ -0,0 +1,59 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadless(t *testing.T) {
+	build := Command{ID: 0, Name: "build"}
+	test := Command{ID: 1, Name: "test"}
+	var out bytes.Buffer
+	h := NewHeadless(model{commands: []Command{build, test}, theme: catppuccin}, &out)
+
+	h.Send(result{status: Pending, job: build, trigger: trigger{triggerChange, []string{"main.go"}}})
+	h.Send(outputMsg{id: 0, chunk: "compiling\nlin"})
+	h.Send(outputMsg{id: 0, chunk: "king"})
+	h.Send(result{status: Succeeded, job: build, output: "compiling\nlinking"})
+	h.Send(outputMsg{id: 0, chunk: "too late\n"})
+
+	h.Send(result{status: Pending, job: test, trigger: trigger{reason: triggerManual}})
+	h.Send(result{status: Failed, job: test, output: "Command canceled"})
+
+	lines := bytes.Split(bytes.TrimSpace(out.Bytes()), []byte("\n"))
+	var got []string
+	for _, line := range lines {
+		// drop the timestamp
+		got = append(got, string(line[9:]))
+	}
+	require.Equal(t, []string{
+		"build | started (change: main.go)",
+		"build | compiling",
+		"build | linking",
+		"build | ✅ build finished in 0s",
+		"test  | started (manual)",
+		"test  | Command canceled",
+		"test  | ❌ test failed in 0s",
+	}, got)
+}
+
+func TestRunOnce(t *testing.T) {
+	commands := []Command{
+		{ID: 0, Name: "ok", Cmd: "echo fine", Shell: "sh"},
+		{ID: 1, Name: "bad", Cmd: "echo broken >&2; exit 3", Shell: "sh"},
+	}
+	var out bytes.Buffer
+	code := RunOnce(model{commands: commands, theme: catppuccin}, &out, context.Background(), 1)
+	require.Equal(t, 1, code)
+	require.Contains(t, out.String(), "ok  | fine")
+	require.Contains(t, out.String(), "bad | broken")
+	require.Regexp(t, `✅  ok   succeeded  \S+`, out.String())
+	require.Regexp(t, `❌  bad  failed     \S+`, out.String())
+	require.Contains(t, out.String(), "1 of 2 command(s) failed")
+
+	out.Reset()
+	require.Equal(t, 0, RunOnce(model{commands: commands[:1], theme: catppuccin}, &out, context.Background(), 4))
+}
@@ -0,0 +1,203 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// how many finished runs are kept for each command
+const historyLimit = 50
+
+// pastRun is a finished run kept in a command's history.
+type pastRun struct {
+	number int
+	res    result
+}
+
+// recordRun adds a finished run to its command's history, dropping the oldest
+// past the limit.
+func (m *model) recordRun(res result) {
+	id := res.job.ID
+	m.runCounts[id]++
+	runs := append(m.history[id], pastRun{m.runCounts[id], res})
+	if len(runs) > historyLimit {
+		runs = runs[len(runs)-historyLimit:]
+	}
+	m.history[id] = runs
+
+	if m.historyView != nil && m.historyView.id == id {
+		m.historyView.setRuns(runs)
+	}
+}
+
+// historyView lists a command's past runs, newest first, and opens their
+// output in the pager.
+type historyView struct {
+	id     int
+	title  string
+	runs   []pastRun
+	cursor int
+	width  int
+	height int
+	offset int
+	// number of the run marked to diff against, or 0
+	marked int
+
+	titleStyle    lipgloss.Style
+	selectedStyle lipgloss.Style
+	dimStyle      lipgloss.Style
+}
+
+func newHistoryView(id int, title string, runs []pastRun, theme Theme, width, height int) *historyView {
+	h := &historyView{
+		id:     id,
+		title:  title,
+		width:  width,
+		height: height,
+		titleStyle: lipgloss.NewStyle().
+			Background(lipgloss.Color(theme.Neutral)).
+			Foreground(lipgloss.Color(theme.Foreground)).
+			Padding(0, 1),
+		selectedStyle: lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Primary)),
+		dimStyle:      lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Foreground)).Faint(true),
+	}
+	h.setRuns(runs)
+	return h
+}
+
+// setRuns replaces the runs shown, keeping the same run selected.
+func (h *historyView) setRuns(runs []pastRun) {
+	var selected int
+	if h.cursor < len(h.runs) {
+		selected = h.runs[h.cursor].number
+	}
+
+	h.runs = make([]pastRun, len(runs))
+	for i, r := range runs {
+		h.runs[len(runs)-1-i] = r
+	}
+
+	h.cursor = 0
+	for i, r := range h.runs {
+		if r.number == selected {
+			h.cursor = i
+		}
+	}
+	h.scroll()
+}
+
+func (h *historyView) setSize(width, height int) {
+	h.width, h.height = width, height
+	h.scroll()
+}
+
+func (h *historyView) bodyHeight() int {
+	return max(h.height-2, 1)
+}
+
+// scroll keeps the cursor on screen.
+func (h *historyView) scroll() {
+	if h.cursor < h.offset {
+		h.offset = h.cursor
+	}
+	if h.cursor >= h.offset+h.bodyHeight() {
+		h.offset = h.cursor - h.bodyHeight() + 1
+	}
+}
+
+// update handles a key press, returning whether the view should close, and
+// the run to open or the older and newer runs to diff, if any.
+func (h *historyView) update(msg tea.KeyMsg) (closed bool, open *pastRun, diff []pastRun) {
+	switch msg.String() {
+	case "q", "esc", "ctrl+c":
+		return true, nil, nil
+	case "j", "down":
+		h.cursor = min(h.cursor+1, max(len(h.runs)-1, 0))
+	case "k", "up":
+		h.cursor = max(h.cursor-1, 0)
+	case "g", "home":
+		h.cursor = 0
+	case "G", "end":
+		h.cursor = max(len(h.runs)-1, 0)
+	case "enter", "v":
+		if h.cursor < len(h.runs) {
+			return false, &h.runs[h.cursor], nil
+		}
+	case " ", "m":
+		if h.cursor < len(h.runs) {
+			if h.marked == h.runs[h.cursor].number {
+				h.marked = 0
+			} else {
+				h.marked = h.runs[h.cursor].number
+			}
+		}
+	case "d":
+		return false, nil, h.diffRuns()
+	}
+	h.scroll()
+	return false, nil, nil
+}
+
+// diffRuns returns the marked run and the selected one, or the selected run
+// and the one before it, oldest first.
+func (h *historyView) diffRuns() []pastRun {
+	if h.cursor >= len(h.runs) {
+		return nil
+	}
+	selected := h.runs[h.cursor]
+	for _, r := range h.runs {
+		if h.marked != 0 && r.number == h.marked && r.number != selected.number {
+			if r.number < selected.number {
+				return []pastRun{r, selected}
+			}
+			return []pastRun{selected, r}
+		}
+	}
+	if h.cursor+1 < len(h.runs) {
+		return []pastRun{h.runs[h.cursor+1], selected}
+	}
+	return nil
+}
+
+func (h *historyView) view() string {
+	rows := make([]string, 0, h.bodyHeight())
+	if len(h.runs) == 0 {
+		rows = append(rows, h.dimStyle.Render("  No finished runs yet"))
+	}
+	for i := h.offset; i < len(h.runs) && len(rows) < h.bodyHeight(); i++ {
+		mark := " "
+		if h.runs[i].number == h.marked {
+			mark = "*"
+		}
+		line := ansi.Truncate(mark+runSummary(h.runs[i]), max(h.width-2, 0), "…")
+		if i == h.cursor {
+			rows = append(rows, h.selectedStyle.Render("> "+line))
+		} else {
+			rows = append(rows, "  "+line)
+		}
+	}
+	for len(rows) < h.bodyHeight() {
+		rows = append(rows, "")
+	}
+
+	status := fmt.Sprintf("%d run(s), last %d kept • enter view output • d diff with previous or marked • m mark • q close", len(h.runs), historyLimit)
+	return h.titleStyle.Render(ansi.Truncate("History: "+h.title, max(h.width-2, 0), "…")) + "\n" +
+		strings.Join(rows, "\n") + "\n" +
+		h.dimStyle.Render(ansi.Truncate(status, h.width, "…"))
+}
+
+// runSummary describes a run on one line, like #3 ❌ 14:03:05 1.2s change: main.go.
+func runSummary(r pastRun) string {
+	d := r.res.duration.Truncate(time.Millisecond)
+	return fmt.Sprintf("#%d %s %s %8s  %s", r.number, getEmoji(r.res.status), r.res.started.Format(time.TimeOnly), d, r.res.trigger)
+}
+
+// runTitle is the pager title for a past run.
+func runTitle(r pastRun) string {
+	return fmt.Sprintf("%s #%d • %s • %s", r.res.job.title(), r.number, r.res.started.Format(time.DateTime), r.res.trigger)
+}
@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// Hook is a shell command run, or a URL posted to, when a run finishes.
+type Hook struct {
+	Cmd     string        `yaml:"cmd,omitempty" desc:"Shell command to run with the command's shell and env, given the run's output on stdin and PANOPTICON_COMMAND, PANOPTICON_STATUS, PANOPTICON_EXIT_CODE, PANOPTICON_DURATION_MS, PANOPTICON_TRIGGER and PANOPTICON_FILES set."`
+	URL     string        `yaml:"url,omitempty" desc:"URL to POST the run_finished event to as JSON, as described by panopticon_events.schema.json."`
+	Timeout time.Duration `yaml:"timeout,omitempty" desc:"How long each attempt may take, such as 30s. Defaults to 10s."`
+	Retries int           `yaml:"retries,omitempty" desc:"How many more times to try if the command exits non-zero or the URL doesn't respond with a 2xx status, waiting 1s, then 2s, and so on."`
+}
+
+// Hooks are run when a command's run finishes, in the background.
+type Hooks struct {
+	OnSuccess []Hook `yaml:"on_success,omitempty" desc:"Hooks run when a run succeeds."`
+	OnFailure []Hook `yaml:"on_failure,omitempty" desc:"Hooks run when a run fails."`
+	OnFinish  []Hook `yaml:"on_finish,omitempty" desc:"Hooks run when a run finishes, after on_success or on_failure."`
+}
+
+func (h Hooks) empty() bool {
+	return len(h.OnSuccess) == 0 && len(h.OnFailure) == 0 && len(h.OnFinish) == 0
+}
+
+// forStatus returns the hooks to run for a run finishing with status.
+func (h Hooks) forStatus(status Status) []Hook {
+	var hooks []Hook
+	switch status {
+	case Succeeded:
+		hooks = append(hooks, h.OnSuccess...)
+	case Failed:
+		hooks = append(hooks, h.OnFailure...)
+	}
+	return append(hooks, h.OnFinish...)
+}
+
+func (h Hooks) interpolate(vars map[string]string) Hooks {
+	expand := func(hooks []Hook) []Hook {
+		if hooks == nil {
+			return nil
+		}
+		out := make([]Hook, len(hooks))
+		for i, hook := range hooks {
+			hook.Cmd = interpolate(hook.Cmd, vars)
+			hook.URL = interpolate(hook.URL, vars)
+			out[i] = hook
+		}
+		return out
+	}
+	return Hooks{expand(h.OnSuccess), expand(h.OnFailure), expand(h.OnFinish)}
+}
+
+// how long a hook attempt may take without a timeout
+const defaultHookTimeout = 10 * time.Second
+
+// hookRetryDelay is the wait before a hook's first retry, doubling after each.
+var hookRetryDelay = time.Second
+
+// hookRunner runs a finished run's hooks, its command's own followed by the
+// global ones.
+type hookRunner struct {
+	global Hooks
+	client *http.Client
+	wg     sync.WaitGroup
+}
+
+// newHookRunner returns nil if neither the config nor any command has hooks.
+func newHookRunner(global Hooks, commands []Command) *hookRunner {
+	if global.empty() {
+		configured := false
+		for _, cmd := range commands {
+			configured = configured || !cmd.Hooks.empty()
+		}
+		if !configured {
+			return nil
+		}
+	}
+	return &hookRunner{global: global, client: &http.Client{}}
+}
+
+// Send starts the hooks of a finished run. Canceled runs don't run hooks.
+func (r *hookRunner) Send(msg tea.Msg) {
+	res, ok := msg.(result)
+	if !ok || res.status == Pending || res.canceled {
+		return
+	}
+	hooks := append(res.job.Hooks.forStatus(res.status), r.global.forStatus(res.status)...)
+	if len(hooks) == 0 {
+		return
+	}
+
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		// in order, so on_finish follows on_success and on_failure
+		for _, hook := range hooks {
+			r.run(hook, res)
+		}
+	}()
+}
+
+// wait blocks until every started hook has finished.
+func (r *hookRunner) wait() {
+	if r != nil {
+		r.wg.Wait()
+	}
+}
+
+// run tries a hook until it succeeds or runs out of retries.
+func (r *hookRunner) run(hook Hook, res result) {
+	timeout := hook.Timeout
+	if timeout == 0 {
+		timeout = defaultHookTimeout
+	}
+
+	delay := hookRetryDelay
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		var err error
+		if hook.URL != "" {
+			err = r.post(ctx, hook.URL, res)
+		} else {
+			err = runHookCommand(ctx, hook.Cmd, res)
+		}
+		cancel()
+		if err == nil {
+			return
+		}
+		if attempt >= hook.Retries {
+			log.Printf("Error running hook for %s: %v", res.job.title(), err)
+			return
+		}
+		log.Printf("Error running hook for %s, retrying in %s: %v", res.job.title(), delay, err)
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+func (r *hookRunner) post(ctx context.Context, target string, res result) error {
+	e := runFinishedEvent(res)
+	e.Version = eventsVersion
+	e.Time = res.started.Add(res.duration)
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("POST %s: %s", target, resp.Status)
+	}
+	return nil
+}
+
+func runHookCommand(ctx context.Context, hookCmd string, res result) error {
+	cmd := shellCommandContext(ctx, res.job.Shell, hookCmd)
+	cmd.Env = append(commandEnv(res.job.Env), resultEnv(res)...)
+	cmd.Stdin = strings.NewReader(res.output)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", hookCmd, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// resultEnv describes a finished run in environment variables.
+func resultEnv(res result) []string {
+	return []string{
+		"PANOPTICON_COMMAND=" + res.job.title(),
+		"PANOPTICON_STATUS=" + strings.ToLower(res.status.String()),
+		fmt.Sprintf("PANOPTICON_EXIT_CODE=%d", res.exitCode),
+		fmt.Sprintf("PANOPTICON_DURATION_MS=%d", res.duration.Milliseconds()),
+		"PANOPTICON_TRIGGER=" + res.trigger.reason,
+		"PANOPTICON_FILES=" + strings.Join(res.trigger.files, "\n"),
+	}
+}
+
+// validateDefaultsHooks reports hooks set in defaults, which aren't
+// inherited: hooks at the top of the config already run for every command.
+func validateDefaultsHooks(file string, doc *yaml.Node) []Diagnostic {
+	var diagnostics []Diagnostic
+	defaults := mappingValue(doc, "defaults")
+	for _, key := range []string{"on_success", "on_failure", "on_finish"} {
+		if node := mappingValue(defaults, key); node != nil {
+			diagnostics = append(diagnostics, nodeDiagnostic(file, node, "defaults: %s isn't inherited, set it at the top level to run it for every command", key))
+		}
+	}
+	return diagnostics
+}
+
+// validateHooks checks that each hook in node, a command or the top of a
+// config, has either a cmd or an http(s) url.
+func validateHooks(file string, node *yaml.Node, label string, hooks Hooks) []Diagnostic {
+	var diagnostics []Diagnostic
+	check := func(key string, list []Hook) {
+		for i, hook := range list {
+			at := node
+			if seq := mappingValue(node, key); seq != nil && seq.Kind == yaml.SequenceNode && i < len(seq.Content) {
+				at = seq.Content[i]
+			}
+			name := fmt.Sprintf("%s[%d]", key, i)
+			if label != "" {
+				name = label + ": " + name
+			}
+
+			switch {
+			case hook.Cmd == "" && hook.URL == "":
+				diagnostics = append(diagnostics, nodeDiagnostic(file, at, "%s: hook needs a cmd or a url", name))
+			case hook.Cmd != "" && hook.URL != "":
+				diagnostics = append(diagnostics, nodeDiagnostic(file, at, "%s: hook has both a cmd and a url", name))
+			case hook.URL != "":
+				if u, err := url.Parse(hook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+					diagnostics = append(diagnostics, nodeDiagnostic(file, at, "%s: invalid hook url %q", name, hook.URL))
+				}
+			}
+			if hook.Retries < 0 {
+				diagnostics = append(diagnostics, nodeDiagnostic(file, at, "%s: retries can't be negative", name))
+			}
+		}
+	}
+	check("on_success", hooks.OnSuccess)
+	check("on_failure", hooks.OnFailure)
+	check("on_finish", hooks.OnFinish)
+	return diagnostics
+}
@@ -0,0 +1,138 @@
+package internal
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+func TestHookURL(t *testing.T) {
+	hookRetryDelay = time.Millisecond
+	defer func() { hookRetryDelay = time.Second }()
+
+	var mu sync.Mutex
+	var events []Event
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		// the chat bot is flaky
+		if attempts == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		var e Event
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&e))
+		events = append(events, e)
+	}))
+	defer ts.Close()
+
+	cmd := Command{ID: 1, Name: "test", Cmd: "go test", Hooks: Hooks{OnFailure: []Hook{{URL: ts.URL, Retries: 1}}}}
+	r := newHookRunner(Hooks{}, []Command{cmd})
+	r.Send(result{status: Pending, job: cmd})
+	r.Send(result{status: Succeeded, job: cmd})
+	r.Send(result{status: Failed, job: cmd, output: canceledOutput, exitCode: -1, canceled: true})
+	r.Send(result{status: Failed, job: cmd, output: "FAIL", exitCode: 1, duration: 2 * time.Second})
+	r.wait()
+
+	require.Equal(t, 2, attempts)
+	require.Len(t, events, 1)
+	e := events[0]
+	require.Equal(t, eventsVersion, e.Version)
+	require.Equal(t, eventRunFinished, e.Type)
+	require.Equal(t, EventCommand{ID: 1, Name: "test", Cmd: "go test"}, *e.Command)
+	require.Equal(t, "failed", e.Status)
+	require.Equal(t, 1, *e.ExitCode)
+	require.Equal(t, int64(2000), e.DurationMs)
+	require.Equal(t, "FAIL", e.Output)
+}
+
+func TestHookURLGivesUp(t *testing.T) {
+	hookRetryDelay = time.Millisecond
+	defer func() { hookRetryDelay = time.Second }()
+
+	var mu sync.Mutex
+	attempts := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		attempts++
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	r := newHookRunner(Hooks{OnFinish: []Hook{{URL: ts.URL, Timeout: 10 * time.Millisecond, Retries: 2}}}, nil)
+	r.Send(result{status: Succeeded, job: Command{Name: "build"}})
+	r.wait()
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, 3, attempts)
+}
+
+func TestHookCommand(t *testing.T) {
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	cmd := Command{
+		Name:  "test",
+		Shell: "sh",
+		Env:   map[string]string{"GREETING": "hi"},
+		Hooks: Hooks{
+			OnSuccess: []Hook{{Cmd: "echo success >> " + out}},
+			OnFailure: []Hook{{Cmd: `echo "$GREETING $PANOPTICON_COMMAND $PANOPTICON_STATUS $PANOPTICON_EXIT_CODE $PANOPTICON_TRIGGER $PANOPTICON_FILES" >> ` + out + ` && cat >> ` + out}},
+		},
+	}
+	global := Hooks{OnFinish: []Hook{{Cmd: "echo finish >> " + out}}}
+	r := newHookRunner(global, []Command{cmd})
+	r.Send(result{status: Failed, job: cmd, output: "FAIL\n", exitCode: 1, trigger: trigger{reason: triggerChange, files: []string{"main.go"}}})
+	r.wait()
+	r.Send(result{status: Succeeded, job: cmd})
+	r.wait()
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "hi test failed 1 change main.go\nFAIL\nfinish\nsuccess\nfinish\n", string(data))
+}
+
+func TestNewHookRunner(t *testing.T) {
+	require.Nil(t, newHookRunner(Hooks{}, []Command{{Name: "test"}}))
+	require.NotNil(t, newHookRunner(Hooks{}, []Command{{Name: "test", Hooks: Hooks{OnFinish: []Hook{{Cmd: "true"}}}}}))
+	require.NotNil(t, newHookRunner(Hooks{OnFailure: []Hook{{Cmd: "true"}}}, nil))
+}
+
+func TestValidateHooks(t *testing.T) {
+	var node yaml.Node
+	require.NoError(t, yaml.Unmarshal([]byte(`on_failure:
+  - cmd: ./page.sh
+  - {}
+  - cmd: ./page.sh
+    url: http://localhost:8080
+on_finish:
+  - url: localhost:8080/hook
+    retries: -1
+`), &node))
+
+	var hooks Hooks
+	require.NoError(t, node.Decode(&hooks))
+	var messages []string
+	for _, d := range validateHooks("panopticon.yaml", node.Content[0], "commands[0]", hooks) {
+		messages = append(messages, d.String())
+	}
+	require.Equal(t, []string{
+		"panopticon.yaml:3:5: commands[0]: on_failure[1]: hook needs a cmd or a url",
+		"panopticon.yaml:4:5: commands[0]: on_failure[2]: hook has both a cmd and a url",
+		`panopticon.yaml:7:5: commands[0]: on_finish[0]: invalid hook url "localhost:8080/hook"`,
+		"panopticon.yaml:7:5: commands[0]: on_finish[0]: retries can't be negative",
+	}, messages)
+}
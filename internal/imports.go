@@ -0,0 +1,276 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// Import turns the entries of a task file into commands at load time.
+type Import struct {
+	From        string   `yaml:"from" validate:"required" desc:"Procfile, Makefile, justfile or package.json to import commands from."`
+	Only        []string `yaml:"only,omitempty" desc:"Glob patterns of entry names to import. Imports every entry if empty."`
+	WatchPaths  []string `yaml:"watch_paths,omitempty" desc:"Paths watched by the imported commands. Defaults apply if empty."`
+	IgnorePaths []string `yaml:"ignore_paths,omitempty" desc:"Paths ignored by the imported commands. Defaults apply if empty."`
+}
+
+// starting point for panopticon import when there's no config yet
+const importedCommandConfig = `# yaml-language-server: $schema=panopticon.schema.json
+defaults:
+  watch_paths:
+    - ./
+`
+
+var (
+	procfilePattern  = regexp.MustCompile(`^([A-Za-z0-9_-]+):\s*(.+)$`)
+	shellSafePattern = regexp.MustCompile(`^[A-Za-z0-9_./-]+$`)
+)
+
+// importEntries expands each import into config entries pointing back at the import node.
+func importEntries(file string, doc *yaml.Node, imports []Import) ([]configEntry, []Diagnostic) {
+	var entries []configEntry
+	var diagnostics []Diagnostic
+	for i, imp := range imports {
+		node := sequenceItem(doc, "imports", i)
+		label := fmt.Sprintf("imports[%d]", i)
+
+		cmds, err := importCommands(imp)
+		if err != nil {
+			diagnostics = append(diagnostics, nodeDiagnostic(file, node, "%s: %v", label, err))
+			continue
+		}
+		for _, cmd := range cmds {
+			entries = append(entries, configEntry{cmd, file, doc, node, fmt.Sprintf("%s (%s)", label, cmd.Name)})
+		}
+	}
+	return entries, diagnostics
+}
+
+// importCommands reads the commands an import refers to.
+func importCommands(imp Import) ([]Command, error) {
+	if imp.From == "" {
+		return nil, fmt.Errorf("missing required field %q", "from")
+	}
+
+	var matchers []glob.Glob
+	for _, pattern := range imp.Only {
+		g, err := glob.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		matchers = append(matchers, g)
+	}
+
+	cmds, err := readTaskFile(imp.From)
+	if err != nil {
+		return nil, err
+	}
+
+	var imported []Command
+	for _, cmd := range cmds {
+		if len(matchers) > 0 && !matchesAny(matchers, cmd.Name) {
+			continue
+		}
+		cmd.WatchPaths = imp.WatchPaths
+		cmd.IgnorePaths = imp.IgnorePaths
+		imported = append(imported, cmd)
+	}
+	return imported, nil
+}
+
+func matchesAny(matchers []glob.Glob, s string) bool {
+	for _, g := range matchers {
+		if g.Match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+// readTaskFile reads the named entries of a task file as commands, based on its file name.
+func readTaskFile(path string) ([]Command, error) {
+	dir, base := filepath.Split(path)
+	dir = filepath.Clean(dir)
+	// commands run from the project root, so step into the file's directory first
+	prefix := ""
+	if dir != "." {
+		prefix = "cd " + shellQuote(dir) + " && "
+	}
+
+	var cmds []Command
+	switch {
+	case strings.HasPrefix(base, "Procfile"):
+		entries, err := procfileEntries(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			cmds = append(cmds, Command{Name: e[0], Cmd: prefix + e[1]})
+		}
+	case base == "package.json":
+		scripts, err := packageScripts(path)
+		if err != nil {
+			return nil, err
+		}
+		pm := packageManager(dir)
+		for _, name := range sortedKeys(scripts) {
+			if isLifecycleScript(name, scripts) {
+				continue
+			}
+			cmds = append(cmds, Command{Name: name, Cmd: prefix + pm + " run " + name})
+		}
+	case base == "Makefile", base == "makefile", base == "GNUmakefile", strings.HasSuffix(base, ".mk"):
+		targets, err := makeTargets(path)
+		if err != nil {
+			return nil, err
+		}
+		makeCmd := "make "
+		if base != "Makefile" && base != "makefile" && base != "GNUmakefile" {
+			makeCmd = "make -f " + shellQuote(base) + " "
+		}
+		for _, target := range targets {
+			cmds = append(cmds, Command{Name: target, Cmd: prefix + makeCmd + target})
+		}
+	case strings.EqualFold(base, "justfile"), base == ".justfile":
+		recipes, err := justRecipes(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, recipe := range recipes {
+			cmds = append(cmds, Command{Name: recipe, Cmd: prefix + "just " + recipe})
+		}
+	default:
+		return nil, fmt.Errorf("don't know how to import %q (expected a Procfile, Makefile, justfile or package.json)", path)
+	}
+
+	return cmds, nil
+}
+
+// procfileEntries returns the name and command of each process in a Procfile.
+func procfileEntries(path string) ([][2]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries [][2]string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if m := procfilePattern.FindStringSubmatch(line); m != nil {
+			entries = append(entries, [2]string{m[1], m[2]})
+		}
+	}
+	return entries, scanner.Err()
+}
+
+func shellQuote(s string) string {
+	if shellSafePattern.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// AddedImports is what AddImports did with each file.
+type AddedImports struct {
+	// files newly imported
+	Added []string
+	// files panopticon.yaml already imports
+	Skipped []string
+	// commands from the added files
+	Commands []Command
+}
+
+// AddImports appends imports of the given files to panopticon.yaml, creating it
+// if needed, skipping files it already imports.
+func AddImports(files []string) (AddedImports, error) {
+	var added AddedImports
+	projectFile, err := findConfigFile(commandFileBase)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return added, err
+	}
+	if filepath.Ext(projectFile) != ".yaml" {
+		return added, fmt.Errorf("can only add imports to a YAML config, add them to %s by hand", filepath.Clean(projectFile))
+	}
+
+	var root yaml.Node
+	data, err := os.ReadFile(projectFile)
+	if os.IsNotExist(err) {
+		data, err = []byte(importedCommandConfig), nil
+	}
+	if err != nil {
+		return added, err
+	}
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return added, err
+	}
+	if len(root.Content) == 0 {
+		root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		return added, fmt.Errorf("%s: expected a mapping at the top level", filepath.Clean(projectFile))
+	}
+
+	imports := mappingValue(doc, "imports")
+	if imports == nil {
+		imports = &yaml.Node{Kind: yaml.SequenceNode}
+		doc.Content = append(doc.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "imports"}, imports)
+	}
+	// without watch paths in defaults, each import needs its own
+	var watchPaths []string
+	if paths := mappingValue(mappingValue(doc, "defaults"), "watch_paths"); paths == nil || len(paths.Content) == 0 {
+		watchPaths = []string{"./"}
+	}
+	for _, file := range files {
+		duplicate := false
+		for _, item := range imports.Content {
+			var existing Import
+			if item.Decode(&existing) == nil && filepath.Clean(existing.From) == filepath.Clean(file) {
+				duplicate = true
+			}
+		}
+		if duplicate {
+			added.Skipped = append(added.Skipped, file)
+			continue
+		}
+
+		imp := Import{From: file, WatchPaths: watchPaths}
+		cmds, err := importCommands(imp)
+		if err != nil {
+			return added, err
+		}
+		var item yaml.Node
+		if err := item.Encode(imp); err != nil {
+			return added, err
+		}
+		imports.Content = append(imports.Content, &item)
+		added.Added = append(added.Added, file)
+		added.Commands = append(added.Commands, cmds...)
+	}
+	if len(added.Added) == 0 {
+		return added, nil
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return added, err
+	}
+	if err := enc.Close(); err != nil {
+		return added, err
+	}
+
+	return added, os.WriteFile(projectFile, buf.Bytes(), 0o644)
+}
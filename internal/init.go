@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const sampleCommandConfig = `# yaml-language-server: $schema=panopticon.schema.json
+commands:
+  - cmd: "echo 'Hello, World!'"
+    watch_paths:
+      - ./
+theme: "default"
+`
+
+// InitConfig writes a panopticon.yaml with commands for the toolchains found
+// in the current directory. Unless yes is set, the user picks which to include.
+func InitConfig(yes bool) error {
+	// don't if file exists
+	if _, err := os.Stat("panopticon.yaml"); err == nil {
+		log.Println("panopticon.yaml already exists")
+		return nil
+	}
+
+	toolchains := detectToolchains(".")
+	if len(toolchains) == 0 {
+		log.Println("No known project files found, creating sample panopticon.yaml")
+		return os.WriteFile("panopticon.yaml", []byte(sampleCommandConfig), 0o644)
+	}
+
+	if !yes {
+		picked, ok, err := pickCommands(toolchains)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			log.Println("Canceled, panopticon.yaml not written")
+			return nil
+		}
+		toolchains = picked
+	}
+
+	content := renderCommandConfig(toolchains)
+	if content == "" {
+		log.Println("No commands selected, panopticon.yaml not written")
+		return nil
+	}
+
+	log.Println("Creating panopticon.yaml")
+	return os.WriteFile("panopticon.yaml", []byte(content), 0o644)
+}
+
+// renderCommandConfig writes the selected proposals as a commented panopticon.yaml.
+func renderCommandConfig(toolchains []toolchain) string {
+	var detected []string
+	var b strings.Builder
+	for _, tc := range toolchains {
+		var selected []proposal
+		for _, p := range tc.commands {
+			if p.selected {
+				selected = append(selected, p)
+			}
+		}
+		if len(selected) == 0 {
+			continue
+		}
+		detected = append(detected, fmt.Sprintf("%s (%s)", tc.name, tc.file))
+
+		fmt.Fprintf(&b, "\n  # %s, from %s\n", tc.name, tc.file)
+		for _, p := range selected {
+			if p.comment != "" {
+				fmt.Fprintf(&b, "  # %s\n", p.comment)
+			}
+			fmt.Fprintf(&b, "  - cmd: %s\n", yamlScalar(p.cmd.Cmd))
+			writeList(&b, "watch_paths", p.cmd.WatchPaths)
+			writeList(&b, "ignore_paths", p.cmd.IgnorePaths)
+		}
+	}
+	if len(detected) == 0 {
+		return ""
+	}
+
+	return "# yaml-language-server: $schema=panopticon.schema.json\n" +
+		"# Generated by panopticon init for " + strings.Join(detected, ", ") + "\n" +
+		"commands:" + b.String() +
+		"\ntheme: \"default\"\n"
+}
+
+func writeList(b *strings.Builder, key string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	fmt.Fprintf(b, "    %s:\n", key)
+	for _, v := range values {
+		fmt.Fprintf(b, "      - %s\n", yamlScalar(v))
+	}
+}
+
+// yamlScalar quotes s only if YAML needs it to read back the same string.
+func yamlScalar(s string) string {
+	out, err := yaml.Marshal(s)
+	if err != nil {
+		return fmt.Sprintf("%q", s)
+	}
+	return strings.TrimSuffix(string(out), "\n")
+}
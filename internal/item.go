@@ -30,6 +30,7 @@ type item struct {
 func setColors(color lipgloss.Color) {
 	selectedItemStyle = selectedItemStyle.Foreground(color)
 	viewportStyle = viewportStyle.BorderForeground(color)
+	paneStyle = paneStyle.BorderForeground(color)
 }
 
 func (i item) FilterValue() string { return i.title + i.body }
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+)
+
+// KeyMap configures the keys bound to each action in the TUI.
+type KeyMap struct {
+	Run        []string `yaml:"run,omitempty" desc:"Run the selected command now."`
+	Toggle     []string `yaml:"toggle,omitempty" desc:"Show or hide the selected command's output."`
+	Pager      []string `yaml:"pager,omitempty" desc:"Open the selected command's output full-screen."`
+	History    []string `yaml:"history,omitempty" desc:"Browse the selected command's past runs."`
+	Diff       []string `yaml:"diff,omitempty" desc:"Compare the selected command's last two runs."`
+	ScrollDown []string `yaml:"scroll_down,omitempty" desc:"Scroll down in the output."`
+	ScrollUp   []string `yaml:"scroll_up,omitempty" desc:"Scroll up in the output."`
+	NextError  []string `yaml:"next_error,omitempty" desc:"Select the next error location in the output."`
+	PrevError  []string `yaml:"prev_error,omitempty" desc:"Select the previous error location in the output."`
+	OpenError  []string `yaml:"open_error,omitempty" desc:"Open the selected error location in $VISUAL or $EDITOR."`
+	Quit       []string `yaml:"quit,omitempty" desc:"Quit panopticon."`
+}
+
+var defaultKeys = KeyMap{
+	Run:        []string{"r"},
+	Toggle:     []string{"enter"},
+	Pager:      []string{"v"},
+	History:    []string{"H"},
+	Diff:       []string{"D"},
+	ScrollDown: []string{"ctrl+j", "ctrl+down"},
+	ScrollUp:   []string{"ctrl+k", "ctrl+up"},
+	NextError:  []string{"e"},
+	PrevError:  []string{"E"},
+	OpenError:  []string{"o"},
+	Quit:       []string{"q", "ctrl+c", "esc"},
+}
+
+// applyKeyDefaults fills in any action keys leaves unbound from defaults.
+func applyKeyDefaults(keys, defaults KeyMap) KeyMap {
+	v := reflect.ValueOf(&keys).Elem()
+	d := reflect.ValueOf(defaults)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Len() == 0 {
+			v.Field(i).Set(d.Field(i))
+		}
+	}
+	return keys
+}
+
+// keyBindings are the bindings the TUI matches key presses against.
+type keyBindings struct {
+	run        key.Binding
+	toggle     key.Binding
+	pager      key.Binding
+	history    key.Binding
+	diff       key.Binding
+	scrollDown key.Binding
+	scrollUp   key.Binding
+	nextError  key.Binding
+	prevError  key.Binding
+	openError  key.Binding
+	quit       key.Binding
+}
+
+func newKeyBindings(keys KeyMap) keyBindings {
+	return keyBindings{
+		run:        binding(keys.Run, "run command now"),
+		toggle:     binding(keys.Toggle, "view output"),
+		pager:      binding(keys.Pager, "full-screen output"),
+		history:    binding(keys.History, "run history"),
+		diff:       binding(keys.Diff, "diff last two runs"),
+		scrollDown: binding(keys.ScrollDown, "scroll down in viewport"),
+		scrollUp:   binding(keys.ScrollUp, "scroll up in viewport"),
+		nextError:  binding(keys.NextError, "next error"),
+		prevError:  binding(keys.PrevError, "previous error"),
+		openError:  binding(keys.OpenError, "open error in editor"),
+		quit:       binding(keys.Quit, "quit"),
+	}
+}
+
+func binding(keys []string, help string) key.Binding {
+	return key.NewBinding(
+		key.WithKeys(keys...),
+		key.WithHelp(helpKeys(keys), help),
+	)
+}
+
+// helpKeys formats keys for the help view, like ctrl+j/ctrl+↓.
+func helpKeys(keys []string) string {
+	arrows := strings.NewReplacer("up", "↑", "down", "↓", "left", "←", "right", "→")
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = arrows.Replace(k)
+	}
+	return strings.Join(names, "/")
+}
@@ -0,0 +1,121 @@
+package internal
+
+import (
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+	"gopkg.in/yaml.v3"
+)
+
+// layouts of the command list and output
+const (
+	// output opens beneath the selected command with the toggle key
+	layoutInline = "inline"
+	// output fills a pane to the right of the list
+	layoutSplit = "split"
+	// output fills a pane below the list
+	layoutStacked = "stacked"
+)
+
+var layouts = []string{layoutInline, layoutSplit, layoutStacked}
+
+var paneStyle = lipgloss.NewStyle().Border(lipgloss.NormalBorder()).Padding(0, 1)
+
+// outputMsg is a chunk of output from a running command.
+type outputMsg struct {
+	id    int
+	chunk string
+	// when the run it's from started
+	started time.Time
+}
+
+// of reports whether the chunk is from res, a run still going, rather than
+// still being copied from one that was canceled or replaced.
+func (msg outputMsg) of(res result) bool {
+	return res.status == Pending && msg.started.Equal(res.started)
+}
+
+// outputWriter sends everything written to it on as it arrives.
+type outputWriter struct {
+	id      int
+	started time.Time
+	p       Sender
+}
+
+func (w outputWriter) Write(b []byte) (int, error) {
+	w.p.Send(outputMsg{w.id, string(b), w.started})
+	return len(b), nil
+}
+
+func (m model) hasPane() bool {
+	return m.layout == layoutSplit || m.layout == layoutStacked
+}
+
+// refreshPane shows the selected command's output in the pane. The pane
+// follows the end of the output unless it's been scrolled up, and jumps to
+// the end when the selection changes.
+func (m *model) refreshPane() {
+	i, ok := m.list.SelectedItem().(item)
+	if !ok {
+		return
+	}
+	follow := i.id != m.paneSelected || m.pane.AtBottom()
+	m.paneSelected = i.id
+
+	content := strings.TrimSuffix(i.body, "\n")
+	if m.pane.Width > 0 {
+		content = ansi.Hardwrap(content, m.pane.Width, true)
+	}
+	m.pane.SetContent(content)
+	if follow {
+		m.pane.GotoBottom()
+	}
+}
+
+// paneChanged reports whether msg may change what the pane shows: the
+// selected command's output, the selection or the pane's size. Spinner and
+// progress bar frames, and output of other commands, don't.
+func (m model) paneChanged(msg tea.Msg) bool {
+	i, ok := m.list.SelectedItem().(item)
+	if !ok || i.id != m.paneSelected {
+		return true
+	}
+	switch msg := msg.(type) {
+	case outputMsg:
+		return msg.id == i.id
+	case result:
+		return msg.job.ID == i.id
+	case spinner.TickMsg, progress.FrameMsg:
+		return false
+	}
+	return true
+}
+
+func (m model) paneView() string {
+	if m.layout == layoutStacked {
+		return lipgloss.JoinVertical(lipgloss.Left, m.list.View(), paneStyle.Render(m.pane.View()))
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, m.list.View(), paneStyle.Render(m.pane.View()))
+}
+
+func validLayout(layout string) bool {
+	for _, l := range layouts {
+		if l == layout {
+			return true
+		}
+	}
+	return false
+}
+
+func validateLayout(file string, root *yaml.Node, layout string) []Diagnostic {
+	if layout == "" || validLayout(layout) {
+		return nil
+	}
+	node := mappingValue(documentNode(root), "layout")
+	return []Diagnostic{nodeDiagnostic(file, node, "unknown layout %q (expected one of %s)", layout, strings.Join(layouts, ", "))}
+}
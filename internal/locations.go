@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// location is a position in a file that a command's output points at.
+type location struct {
+	file     string
+	line     int
+	column   int
+	severity string
+	message  string
+}
+
+func (l location) String() string {
+	s := l.file + ":" + strconv.Itoa(l.line)
+	if l.column > 0 {
+		s += ":" + strconv.Itoa(l.column)
+	}
+	if l.message != "" {
+		s += ": " + l.message
+	}
+	return s
+}
+
+// Built-in matchers use the same named groups as configured ones: file, line,
+// and optionally column, severity and message.
+var builtinMatchers = []*regexp.Regexp{
+	// go build, go vet and go test: path/file.go:42:7: undefined: foo
+	regexp.MustCompile(`^\s*(?P<file>[^\s:]+\.go):(?P<line>\d+)(?::(?P<column>\d+))?:\s*(?P<message>.*)$`),
+	// tsc: src/app.ts(12,5): error TS2322: ...
+	regexp.MustCompile(`^(?P<file>[^\s(]+\.[cm]?[jt]sx?)\((?P<line>\d+),(?P<column>\d+)\):\s*(?P<message>.*)$`),
+	// tsc --pretty: src/app.ts:12:5 - error TS2322: ...
+	regexp.MustCompile(`^(?P<file>[^\s:]+\.[cm]?[jt]sx?):(?P<line>\d+):(?P<column>\d+)\s+-\s+(?P<message>.*)$`),
+}
+
+var (
+	// eslint's default format lists problems under a line with the file's path
+	eslintFilePattern    = regexp.MustCompile(`^(/\S+|[A-Za-z]:\\\S+)$`)
+	eslintProblemPattern = regexp.MustCompile(`^\s+(\d+):(\d+)\s+((?:error|warning)\s+.*)$`)
+	// rustc and cargo give the message first, then --> src/main.rs:12:5
+	rustMessagePattern  = regexp.MustCompile(`^((?:error|warning)(?:\[\w+\])?: .*)$`)
+	rustLocationPattern = regexp.MustCompile(`^\s*--> (\S+):(\d+):(\d+)$`)
+	// python tracebacks: File "app/main.py", line 12, in handler
+	pythonFramePattern = regexp.MustCompile(`^\s*File "([^"]+)", line (\d+)(?:, in (.+))?$`)
+)
+
+// compileMatchers compiles configured matchers, which must have file and line groups.
+func compileMatchers(patterns []string) ([]*regexp.Regexp, error) {
+	var matchers []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid matcher %q: %w", pattern, err)
+		}
+		if re.SubexpIndex("file") < 0 || re.SubexpIndex("line") < 0 {
+			return nil, fmt.Errorf("invalid matcher %q: needs (?P<file>...) and (?P<line>...) groups", pattern)
+		}
+		matchers = append(matchers, re)
+	}
+	return matchers, nil
+}
+
+// parseLocations finds the file locations in output, trying the command's
+// matchers before the built-in ones on each line.
+func parseLocations(output string, patterns []string) []location {
+	matchers, err := compileMatchers(patterns)
+	if err != nil {
+		// reported when the config is loaded
+		matchers = nil
+	}
+	matchers = append(matchers, builtinMatchers...)
+
+	var locations []location
+	seen := make(map[location]bool)
+	add := func(l location) {
+		if l.severity == "" {
+			l.severity = severityOf(l.message)
+		}
+		if l.line > 0 && !seen[l] {
+			seen[l] = true
+			locations = append(locations, l)
+		}
+	}
+
+	var eslintFile, rustMessage string
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if l, ok := matchLocation(line, matchers); ok {
+			add(l)
+			continue
+		}
+
+		switch {
+		case eslintFilePattern.MatchString(line):
+			eslintFile = line
+		case eslintFile != "" && eslintProblemPattern.MatchString(line):
+			m := eslintProblemPattern.FindStringSubmatch(line)
+			add(location{eslintFile, atoi(m[1]), atoi(m[2]), "", strings.Join(strings.Fields(m[3]), " ")})
+		case rustMessagePattern.MatchString(line):
+			rustMessage = line
+		case rustLocationPattern.MatchString(line):
+			m := rustLocationPattern.FindStringSubmatch(line)
+			add(location{m[1], atoi(m[2]), atoi(m[3]), "", rustMessage})
+		case pythonFramePattern.MatchString(line):
+			m := pythonFramePattern.FindStringSubmatch(line)
+			message := ""
+			if m[3] != "" {
+				message = "in " + m[3]
+			}
+			add(location{m[1], atoi(m[2]), 0, "error", message})
+		case line == "":
+			eslintFile = ""
+		}
+	}
+	return locations
+}
+
+func matchLocation(line string, matchers []*regexp.Regexp) (location, bool) {
+	for _, re := range matchers {
+		m := re.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		group := func(name string) string {
+			if i := re.SubexpIndex(name); i >= 0 {
+				return m[i]
+			}
+			return ""
+		}
+		return location{
+			file:     group("file"),
+			line:     atoi(group("line")),
+			column:   atoi(group("column")),
+			severity: strings.ToLower(group("severity")),
+			message:  strings.TrimSpace(group("message")),
+		}, true
+	}
+	return location{}, false
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// editorFinishedMsg is sent when the editor opened from the TUI exits.
+type editorFinishedMsg struct {
+	err error
+}
+
+// openLocation suspends the TUI and opens the location in $VISUAL or $EDITOR.
+func openLocation(l location) tea.Cmd {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := strings.Fields(editor)
+	args = append(args, editorArgs(filepath.Base(args[0]), l)...)
+
+	return tea.ExecProcess(exec.Command(args[0], args[1:]...), func(err error) tea.Msg {
+		return editorFinishedMsg{err}
+	})
+}
+
+// editorArgs returns the arguments that open a file at a line for the editor.
+func editorArgs(editor string, l location) []string {
+	column := max(l.column, 1)
+	switch editor {
+	case "code", "code-insiders", "codium", "cursor", "windsurf":
+		return []string{"--goto", fmt.Sprintf("%s:%d:%d", l.file, l.line, column)}
+	case "subl", "zed", "hx", "helix", "micro":
+		return []string{fmt.Sprintf("%s:%d:%d", l.file, l.line, column)}
+	case "vi", "vim", "nvim", "gvim", "mvim":
+		return []string{fmt.Sprintf("+call cursor(%d,%d)", l.line, column), l.file}
+	case "emacs", "emacsclient":
+		return []string{fmt.Sprintf("+%d:%d", l.line, column), l.file}
+	case "idea", "goland", "webstorm", "pycharm", "rustrover":
+		return []string{"--line", strconv.Itoa(l.line), "--column", strconv.Itoa(column - 1), l.file}
+	default:
+		// nano, kak, joe and most others take +line
+		return []string{fmt.Sprintf("+%d", l.line), l.file}
+	}
+}
+
+var errorLineStyle = lipgloss.NewStyle().PaddingLeft(2)
+
+// selectedLocations returns the locations in the selected command's latest output.
+func (m model) selectedLocations() []location {
+	i, ok := m.list.SelectedItem().(item)
+	if !ok {
+		return nil
+	}
+	return m.locations[i.id]
+}
+
+// cycleError moves through the selected command's error locations by step.
+func (m *model) cycleError(step int) {
+	locations := m.selectedLocations()
+	if len(locations) == 0 {
+		return
+	}
+	if m.errorIndex < 0 {
+		if step > 0 {
+			m.errorIndex = 0
+		} else {
+			m.errorIndex = len(locations) - 1
+		}
+		return
+	}
+	m.errorIndex = (m.errorIndex + step + len(locations)) % len(locations)
+}
+
+func (m model) errorView() string {
+	locations := m.selectedLocations()
+	if len(locations) == 0 {
+		return ""
+	}
+	if m.errorIndex < 0 || m.errorIndex >= len(locations) {
+		return errorLineStyle.Render(fmt.Sprintf("%d error location(s), %s to step through", len(locations), m.keys.nextError.Help().Key))
+	}
+	return errorLineStyle.Render(fmt.Sprintf("%d/%d %s (%s to open)",
+		m.errorIndex+1, len(locations), locations[m.errorIndex], m.keys.openError.Help().Key))
+}
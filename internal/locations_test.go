@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLocations(t *testing.T) {
+	output := `# github.com/cfbender/panopticon/internal
+internal/tui.go:42:7: undefined: foo
+--- FAIL: TestView (0.00s)
+    tui_test.go:51: expected view
+src/app.ts(12,5): error TS2322: Type 'string' is not assignable to type 'number'.
+src/app.ts:14:3 - error TS2304: Cannot find name 'bar'.
+
+/home/me/project/src/index.js
+  3:10  error  'x' is defined but never used  no-unused-vars
+
+error[E0425]: cannot find value ` + "`y`" + ` in this scope
+ --> src/main.rs:4:13
+Traceback (most recent call last):
+  File "app/main.py", line 12, in handler
+ValueError: bad
+build: lib.c line 7 Warning deprecated
+`
+
+	locations := parseLocations(output, []string{`(?P<file>\S+\.c) line (?P<line>\d+) (?P<severity>\w+) (?P<message>.*)$`})
+	require.Equal(t, []location{
+		{"internal/tui.go", 42, 7, "error", "undefined: foo"},
+		{"tui_test.go", 51, 0, "error", "expected view"},
+		{"src/app.ts", 12, 5, "error", "error TS2322: Type 'string' is not assignable to type 'number'."},
+		{"src/app.ts", 14, 3, "error", "error TS2304: Cannot find name 'bar'."},
+		{"/home/me/project/src/index.js", 3, 10, "error", "error 'x' is defined but never used no-unused-vars"},
+		{"src/main.rs", 4, 13, "error", "error[E0425]: cannot find value `y` in this scope"},
+		{"app/main.py", 12, 0, "error", "in handler"},
+		{"lib.c", 7, 0, "warning", "deprecated"},
+	}, locations)
+
+	_, err := compileMatchers([]string{`(?P<file>\S+)`})
+	require.ErrorContains(t, err, "needs (?P<file>...) and (?P<line>...) groups")
+}
+
+func TestEditorArgs(t *testing.T) {
+	l := location{file: "main.go", line: 3, column: 9}
+	require.Equal(t, []string{"+call cursor(3,9)", "main.go"}, editorArgs("nvim", l))
+	require.Equal(t, []string{"--goto", "main.go:3:9"}, editorArgs("code", l))
+	require.Equal(t, []string{"+3", "main.go"}, editorArgs("nano", l))
+}
+
+func TestProblemFiles(t *testing.T) {
+	dir := t.TempDir()
+	f := newProblemFiles(dir)
+
+	build := Command{ID: 0, Name: "build"}
+	lint := Command{ID: 1, Cmd: "eslint ."}
+	require.NoError(t, f.update(result{status: Failed, job: lint}, []location{
+		{"src/index.js", 3, 10, "warning", "'x' is defined but never used"},
+	}))
+	require.NoError(t, f.update(result{status: Failed, job: build}, []location{
+		{"main.go", 4, 2, "error", "undefined: foo"},
+	}))
+
+	quickfix, err := os.ReadFile(filepath.Join(dir, quickfixFile))
+	require.NoError(t, err)
+	require.Equal(t, "main.go:4:2: error: undefined: foo [build]\n"+
+		"src/index.js:3:10: warning: 'x' is defined but never used [eslint .]\n", string(quickfix))
+
+	// a passing run clears its command's problems
+	require.NoError(t, f.update(result{status: Succeeded, job: build}, nil))
+	data, err := os.ReadFile(filepath.Join(dir, diagnosticsFile))
+	require.NoError(t, err)
+	var problems []problem
+	require.NoError(t, json.Unmarshal(data, &problems))
+	require.Equal(t, []problem{{"src/index.js", 3, 10, "warning", "'x' is defined but never used", "eslint ."}}, problems)
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+}
@@ -0,0 +1,145 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// upper bounds of the run duration histogram buckets, in seconds
+var durationBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// histogram counts observations into cumulative buckets, as Prometheus does.
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(durationBuckets))
+	}
+	for i, bound := range durationBuckets {
+		if v <= bound {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// metrics keeps run statistics for each command, written in the Prometheus
+// text format.
+type metrics struct {
+	mu        sync.Mutex
+	commands  []Command
+	runs      map[int]map[Status]uint64
+	durations map[int]*histogram
+	running   map[int]bool
+	watched   map[int]int
+	changes   map[int]uint64
+}
+
+func newMetrics(commands []Command) *metrics {
+	return &metrics{
+		commands:  commands,
+		runs:      make(map[int]map[Status]uint64),
+		durations: make(map[int]*histogram),
+		running:   make(map[int]bool),
+		watched:   make(map[int]int),
+		changes:   make(map[int]uint64),
+	}
+}
+
+func (m *metrics) Send(msg tea.Msg) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch msg := msg.(type) {
+	case result:
+		id := msg.job.ID
+		m.running[id] = msg.status == Pending
+		if msg.status == Pending {
+			return
+		}
+		if m.runs[id] == nil {
+			m.runs[id] = make(map[Status]uint64)
+		}
+		m.runs[id][msg.status]++
+		if m.durations[id] == nil {
+			m.durations[id] = &histogram{}
+		}
+		m.durations[id].observe(msg.duration.Seconds())
+	case watchMsg:
+		m.watched[msg.id] = len(msg.paths)
+	case changeMsg:
+		m.changes[msg.id]++
+	}
+}
+
+func (m *metrics) write(w io.Writer) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	header := func(name, kind, help string) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+
+	header("panopticon_runs_total", "counter", "Finished runs by command and status.")
+	for _, cmd := range m.commands {
+		for _, status := range []Status{Succeeded, Failed} {
+			fmt.Fprintf(w, "panopticon_runs_total{%s,status=%q} %d\n", labels(cmd), strings.ToLower(status.String()), m.runs[cmd.ID][status])
+		}
+	}
+
+	header("panopticon_run_duration_seconds", "histogram", "How long finished runs took.")
+	for _, cmd := range m.commands {
+		h := m.durations[cmd.ID]
+		if h == nil {
+			h = &histogram{counts: make([]uint64, len(durationBuckets))}
+		}
+		for i, bound := range durationBuckets {
+			fmt.Fprintf(w, "panopticon_run_duration_seconds_bucket{%s,le=%q} %d\n", labels(cmd), strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(w, "panopticon_run_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels(cmd), h.count)
+		fmt.Fprintf(w, "panopticon_run_duration_seconds_sum{%s} %s\n", labels(cmd), strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "panopticon_run_duration_seconds_count{%s} %d\n", labels(cmd), h.count)
+	}
+
+	header("panopticon_command_running", "gauge", "Whether the command is running.")
+	for _, cmd := range m.commands {
+		var running int
+		if m.running[cmd.ID] {
+			running = 1
+		}
+		fmt.Fprintf(w, "panopticon_command_running{%s} %d\n", labels(cmd), running)
+	}
+
+	header("panopticon_watched_directories", "gauge", "Directories watched for the command.")
+	for _, cmd := range m.commands {
+		fmt.Fprintf(w, "panopticon_watched_directories{%s} %d\n", labels(cmd), m.watched[cmd.ID])
+	}
+
+	header("panopticon_file_events_total", "counter", "File changes that triggered or will trigger the command.")
+	for _, cmd := range m.commands {
+		fmt.Fprintf(w, "panopticon_file_events_total{%s} %d\n", labels(cmd), m.changes[cmd.ID])
+	}
+}
+
+// labels identifies a command's series by its name and, as names needn't be
+// unique, its ID.
+func labels(cmd Command) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return fmt.Sprintf(`command="%s",id="%d"`, r.Replace(cmd.title()), cmd.ID)
+}
+
+func (srv *Server) getMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	srv.metrics.write(w)
+}
@@ -0,0 +1,54 @@
+package internal
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics(t *testing.T) {
+	build := Command{ID: 0, Name: "build", Cmd: "go build"}
+	test := Command{ID: 1, Name: `say "hi"`, Cmd: "go test"}
+	srv := NewServer(model{commands: []Command{build, test}}, nil)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	srv.Send(watchMsg{0, []string{"cmd", "internal"}})
+	srv.Send(changeMsg{0, "main.go"})
+	srv.Send(changeMsg{0, "main.go"})
+	srv.Send(result{status: Pending, job: build})
+	srv.Send(result{status: Failed, job: build, duration: 300 * time.Millisecond})
+	srv.Send(result{status: Pending, job: build})
+	srv.Send(result{status: Succeeded, job: build, duration: 2 * time.Second})
+	srv.Send(result{status: Pending, job: test})
+
+	res, err := http.Get(ts.URL + "/metrics")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Contains(t, res.Header.Get("Content-Type"), "version=0.0.4")
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	for _, line := range []string{
+		"# TYPE panopticon_runs_total counter",
+		`panopticon_runs_total{command="build",id="0",status="succeeded"} 1`,
+		`panopticon_runs_total{command="build",id="0",status="failed"} 1`,
+		`panopticon_runs_total{command="say \"hi\"",id="1",status="failed"} 0`,
+		`panopticon_run_duration_seconds_bucket{command="build",id="0",le="0.25"} 0`,
+		`panopticon_run_duration_seconds_bucket{command="build",id="0",le="0.5"} 1`,
+		`panopticon_run_duration_seconds_bucket{command="build",id="0",le="2.5"} 2`,
+		`panopticon_run_duration_seconds_bucket{command="build",id="0",le="+Inf"} 2`,
+		`panopticon_run_duration_seconds_sum{command="build",id="0"} 2.3`,
+		`panopticon_run_duration_seconds_count{command="build",id="0"} 2`,
+		`panopticon_command_running{command="build",id="0"} 0`,
+		`panopticon_command_running{command="say \"hi\"",id="1"} 1`,
+		`panopticon_watched_directories{command="build",id="0"} 2`,
+		`panopticon_file_events_total{command="build",id="0"} 2`,
+	} {
+		require.Contains(t, string(body), line+"\n")
+	}
+}
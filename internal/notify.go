@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gopkg.in/yaml.v3"
+)
+
+// transitions notified on
+const (
+	// a run fails after the previous one succeeded
+	notifyBroken = "broken"
+	// a run succeeds after the previous one failed
+	notifyFixed = "fixed"
+	// every failed run
+	notifyFailure = "failure"
+	// every successful run
+	notifySuccess = "success"
+	// turns off notifications set in defaults
+	notifyNone = "none"
+)
+
+var notifyTransitions = []string{notifyBroken, notifyFixed, notifyFailure, notifySuccess, notifyNone}
+
+// ways of notifying
+const (
+	notifyBell    = "bell"
+	notifyOSC9    = "osc9"
+	notifyOSC777  = "osc777"
+	notifyCommand = "command"
+)
+
+var notifyMethods = []string{notifyBell, notifyOSC9, notifyOSC777, notifyCommand}
+
+const (
+	// how long notify_cmd may run for
+	notifyTimeout = 10 * time.Second
+	// least time between notifications for a command without notify_interval
+	defaultNotifyInterval = 10 * time.Second
+)
+
+// notifier notifies when a command's runs change state as configured, at
+// most once per notify_interval for each command. A transition within the
+// interval is held back and sent when it ends, unless the command has
+// changed state again by then.
+type notifier struct {
+	mu       sync.Mutex
+	out      io.Writer
+	terminal bool
+	// the TUI, if it's running, to write terminal notifications between renders
+	program  Sender
+	last     map[int]Status
+	notified map[int]time.Time
+	// status of the last notification sent
+	notifiedStatus map[int]Status
+	held           map[int]heldNotification
+	now            func() time.Time
+	after          func(time.Duration, func())
+}
+
+// notificationMsg is a terminal notification's escape sequence for the TUI
+// to write.
+type notificationMsg string
+
+// NotifyThrough has the model's terminal notifications written by p, the
+// running TUI, rather than straight to the terminal it's drawing on.
+func NotifyThrough(m model, p Sender) {
+	if m.notifier != nil {
+		m.notifier.program = p
+	}
+}
+
+// heldNotification is the latest transition made within notify_interval.
+type heldNotification struct {
+	res        result
+	transition string
+}
+
+// newNotifier writes terminal notifications to out, if it's a terminal.
+func newNotifier(out io.Writer, terminal bool) *notifier {
+	return &notifier{
+		out:      out,
+		terminal: terminal,
+		last:     make(map[int]Status),
+		notified: make(map[int]time.Time),
+
+		notifiedStatus: make(map[int]Status),
+		held:           make(map[int]heldNotification),
+		now:            time.Now,
+		after: func(d time.Duration, f func()) {
+			time.AfterFunc(d, f)
+		},
+	}
+}
+
+func (n *notifier) Send(msg tea.Msg) {
+	res, ok := msg.(result)
+	if !ok || res.status == Pending || res.canceled || len(res.job.NotifyOn) == 0 {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	id := res.job.ID
+	previous, ran := n.last[id]
+	n.last[id] = res.status
+
+	transition, ok := matchTransition(res.job.NotifyOn, res.status, previous, ran)
+	if !ok {
+		return
+	}
+	interval := res.job.NotifyInterval
+	if interval == 0 {
+		interval = defaultNotifyInterval
+	}
+	if at, ok := n.notified[id]; ok && n.now().Sub(at) < interval {
+		wait := interval - n.now().Sub(at)
+		log.Printf("Holding back %s %s for %s", res.job.title(), transition, wait)
+		if _, waiting := n.held[id]; !waiting {
+			n.after(wait, func() { n.release(id) })
+		}
+		n.held[id] = heldNotification{res, transition}
+		return
+	}
+	n.send(res, transition)
+}
+
+// release sends a command's held notification once its interval is over.
+func (n *notifier) release(id int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	held, ok := n.held[id]
+	delete(n.held, id)
+	switch {
+	case !ok:
+	case n.last[id] != held.res.status:
+		log.Printf("Dropping held %s %s, it has changed state since", held.res.job.title(), held.transition)
+	case (held.transition == notifyBroken || held.transition == notifyFixed) && n.notifiedStatus[id] == held.res.status:
+		log.Printf("Dropping held %s %s, it changed back", held.res.job.title(), held.transition)
+	default:
+		n.send(held.res, held.transition)
+	}
+}
+
+func (n *notifier) send(res result, transition string) {
+	n.notified[res.job.ID] = n.now()
+	n.notifiedStatus[res.job.ID] = res.status
+	n.notify(res, transition)
+}
+
+// matchTransition returns the first configured transition a run makes.
+func matchTransition(on []string, status, previous Status, ran bool) (string, bool) {
+	for _, t := range on {
+		switch {
+		case t == notifyBroken && ran && previous == Succeeded && status == Failed,
+			t == notifyFixed && ran && previous == Failed && status == Succeeded,
+			t == notifyFailure && status == Failed,
+			t == notifySuccess && status == Succeeded:
+			return t, true
+		}
+	}
+	return "", false
+}
+
+func (n *notifier) notify(res result, transition string) {
+	message := strings.TrimSpace(getStatus(res))
+	methods := res.job.NotifyWith
+	if len(methods) == 0 {
+		methods = []string{notifyBell}
+		if res.job.NotifyCmd != "" {
+			methods = []string{notifyCommand}
+		}
+	}
+
+	for _, method := range methods {
+		switch method {
+		case notifyCommand:
+			go runNotifyCommand(res, transition, message)
+		default:
+			if !n.terminal {
+				continue
+			}
+			seq := terminalNotification(method, message)
+			if n.program != nil {
+				n.program.Send(notificationMsg(seq))
+				continue
+			}
+			n.write(seq)
+		}
+	}
+}
+
+func (n *notifier) write(seq string) {
+	if _, err := io.WriteString(n.out, seq); err != nil {
+		log.Println("Error notifying:", err)
+	}
+}
+
+// terminalNotification is the escape sequence for a notification, passed
+// through tmux to the terminal outside it.
+func terminalNotification(method, message string) string {
+	message = strings.NewReplacer("\x07", "", "\x1b", "", ";", ",").Replace(message)
+	var seq string
+	switch method {
+	case notifyOSC9:
+		seq = "\x1b]9;" + message + "\x07"
+	case notifyOSC777:
+		seq = "\x1b]777;notify;panopticon;" + message + "\x07"
+	default:
+		return "\a"
+	}
+	if os.Getenv("TMUX") != "" {
+		seq = "\x1bPtmux;" + strings.ReplaceAll(seq, "\x1b", "\x1b\x1b") + "\x1b\\"
+	}
+	return seq
+}
+
+// runNotifyCommand runs notify_cmd with the command's shell, describing the
+// run in environment variables.
+func runNotifyCommand(res result, transition, message string) {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	cmd := shellCommandContext(ctx, res.job.Shell, res.job.NotifyCmd)
+	cmd.Env = append(commandEnv(res.job.Env), resultEnv(res)...)
+	cmd.Env = append(cmd.Env, "PANOPTICON_TRANSITION="+transition, "PANOPTICON_MESSAGE="+message)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		log.Printf("Error running notify_cmd for %s: %v: %s", res.job.title(), err, out)
+	}
+}
+
+func validateNotify(entry configEntry) []Diagnostic {
+	var diagnostics []Diagnostic
+	check := func(key string, values, allowed []string) {
+		for i, v := range values {
+			if contains(allowed, v) {
+				continue
+			}
+			at := entry.node
+			if seq := mappingValue(entry.node, key); seq != nil && seq.Kind == yaml.SequenceNode && i < len(seq.Content) {
+				at = seq.Content[i]
+			}
+			diagnostics = append(diagnostics, nodeDiagnostic(entry.file, at, "%s: unknown %s %q (expected one of %s)", entry.label, key, v, strings.Join(allowed, ", ")))
+		}
+	}
+	check("notify_on", entry.cmd.NotifyOn, notifyTransitions)
+	check("notify_with", entry.cmd.NotifyWith, notifyMethods)
+
+	if contains(entry.cmd.NotifyWith, notifyCommand) && entry.cmd.NotifyCmd == "" {
+		diagnostics = append(diagnostics, nodeDiagnostic(entry.file, entry.node, "%s: notify_with includes command but notify_cmd isn't set", entry.label))
+	}
+	return diagnostics
+}
+
+func contains(values []string, v string) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
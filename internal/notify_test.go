@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/gobwas/glob"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchTransition(t *testing.T) {
+	tests := []struct {
+		on       []string
+		status   Status
+		previous Status
+		ran      bool
+		want     string
+	}{
+		{[]string{"broken"}, Failed, Succeeded, true, "broken"},
+		{[]string{"broken"}, Failed, Failed, true, ""},
+		{[]string{"broken"}, Failed, 0, false, ""},
+		{[]string{"fixed"}, Succeeded, Failed, true, "fixed"},
+		{[]string{"fixed"}, Succeeded, Succeeded, true, ""},
+		{[]string{"failure"}, Failed, Failed, true, "failure"},
+		{[]string{"success"}, Succeeded, 0, false, "success"},
+		{[]string{"broken", "failure"}, Failed, Succeeded, true, "broken"},
+		{[]string{"broken", "failure"}, Failed, Failed, true, "failure"},
+		{[]string{"none"}, Failed, Succeeded, true, ""},
+	}
+	for _, tt := range tests {
+		got, ok := matchTransition(tt.on, tt.status, tt.previous, tt.ran)
+		require.Equal(t, tt.want, got, "%v %v after %v", tt.on, tt.status, tt.previous)
+		require.Equal(t, tt.want != "", ok)
+	}
+}
+
+func TestNotifier(t *testing.T) {
+	t.Setenv("TMUX", "")
+	var out bytes.Buffer
+	n := newNotifier(&out, true)
+	now := time.Unix(0, 0)
+	n.now = func() time.Time { return now }
+	var release func()
+	var wait time.Duration
+	n.after = func(d time.Duration, f func()) { wait, release = d, f }
+
+	cmd := Command{ID: 0, Name: "test", NotifyOn: []string{"broken", "fixed"}, NotifyWith: []string{"osc9"}, NotifyInterval: time.Minute}
+	n.Send(result{status: Pending, job: cmd})
+	n.Send(result{status: Succeeded, job: cmd})
+	require.Empty(t, out.String())
+
+	n.Send(result{status: Failed, job: cmd, exitCode: 1})
+	require.Equal(t, "\x1b]9;❌ test failed in 0s\x07", out.String())
+
+	// within notify_interval, held back until it ends
+	out.Reset()
+	now = now.Add(20 * time.Second)
+	n.Send(result{status: Succeeded, job: cmd})
+	require.Empty(t, out.String())
+	require.Equal(t, 40*time.Second, wait)
+	now = now.Add(wait)
+	release()
+	require.Equal(t, "\x1b]9;✅ test finished in 0s\x07", out.String())
+
+	// changing back within the interval sends nothing more
+	out.Reset()
+	release = nil
+	now = now.Add(time.Second)
+	n.Send(result{status: Failed, job: cmd})
+	n.Send(result{status: Succeeded, job: cmd})
+	now = now.Add(time.Minute)
+	release()
+	require.Empty(t, out.String())
+
+	n.Send(result{status: Succeeded, job: cmd})
+	require.Empty(t, out.String(), "still succeeding isn't a transition")
+	n.Send(result{status: Failed, job: cmd, output: canceledOutput, canceled: true})
+	require.Empty(t, out.String(), "canceled runs aren't notified")
+	n.Send(result{status: Failed, job: cmd})
+	require.Equal(t, "\x1b]9;❌ test failed in 0s\x07", out.String())
+
+	// output that only looks like a cancel
+	out.Reset()
+	echo := Command{ID: 1, Name: "echo", NotifyOn: []string{"failure"}, NotifyWith: []string{"bell"}}
+	n.Send(result{status: Failed, job: echo, output: canceledOutput})
+	require.Equal(t, "\a", out.String())
+
+	// not a terminal
+	out.Reset()
+	n = newNotifier(&out, false)
+	n.Send(result{status: Failed, job: Command{Name: "test", NotifyOn: []string{"failure"}}})
+	require.Empty(t, out.String())
+}
+
+func TestTerminalNotification(t *testing.T) {
+	t.Setenv("TMUX", "")
+	require.Equal(t, "\a", terminalNotification("bell", "test failed"))
+	require.Equal(t, "\x1b]777;notify;panopticon;a, b\x07", terminalNotification("osc777", "a; b\x1b"))
+
+	t.Setenv("TMUX", "/tmp/tmux-1000/default,1,0")
+	require.Equal(t, "\x1bPtmux;\x1b\x1b]9;test failed\x07\x1b\\", terminalNotification("osc9", "test failed"))
+}
+
+func TestNotifyCommand(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "notified")
+	cmd := Command{
+		Name:      "test",
+		Shell:     "sh",
+		NotifyCmd: `echo "$PANOPTICON_COMMAND $PANOPTICON_STATUS $PANOPTICON_TRANSITION $PANOPTICON_EXIT_CODE $PANOPTICON_DURATION_MS $PANOPTICON_MESSAGE" > ` + out,
+	}
+	runNotifyCommand(result{status: Failed, job: cmd, exitCode: 2, duration: 1500 * time.Millisecond}, "broken", "❌ test failed")
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	require.Equal(t, "test failed broken 2 1500 ❌ test failed", strings.TrimSpace(string(data)))
+}
+
+// programRecorder stands in for the running TUI.
+type programRecorder []tea.Msg
+
+func (p *programRecorder) Send(msg tea.Msg) {
+	*p = append(*p, msg)
+}
+
+func TestNotifyThroughTUI(t *testing.T) {
+	t.Setenv("TMUX", "")
+	require.NoError(t, os.WriteFile(commandFile, []byte(sampleConfig), 0o644))
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+	}()
+
+	var out bytes.Buffer
+	var program programRecorder
+	m := NewModel(func() {}, glob.MustCompile("*"), Options{})
+	require.Equal(t, os.Stderr, m.notifier.out, "kept out of stdout without the TUI")
+	m.notifier = newNotifier(&out, true)
+	NotifyThrough(m, &program)
+
+	m.notifier.Send(result{status: Failed, job: Command{Name: "test", NotifyOn: []string{"failure"}}})
+	require.Empty(t, out.String(), "written between renders, not from the run")
+	require.Equal(t, programRecorder{notificationMsg("\a")}, program)
+
+	m.Update(program[0])
+	require.Equal(t, "\a", out.String())
+}
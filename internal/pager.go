@@ -0,0 +1,408 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const pagerHorizontalStep = 8
+
+// pagerMatch is a search match, as byte offsets into a line.
+type pagerMatch struct {
+	line, start, end int
+}
+
+// pager shows a command's output full-screen, with search and horizontal scrolling.
+type pager struct {
+	id    int
+	title string
+	lines []string
+	// the last line as written so far, if it hasn't ended yet
+	partial string
+	width   int
+	height  int
+	yOffset int
+	xOffset int
+	wrap    bool
+
+	searching bool
+	input     textinput.Model
+	query     string
+	matches   []pagerMatch
+	current   int
+
+	titleStyle   lipgloss.Style
+	statusStyle  lipgloss.Style
+	matchStyle   lipgloss.Style
+	currentStyle lipgloss.Style
+	// lineStyle colors whole lines, if set
+	lineStyle func(line string) lipgloss.Style
+}
+
+func newPager(id int, title, content string, theme Theme, width, height int) *pager {
+	input := textinput.New()
+	input.Prompt = "/"
+
+	p := &pager{
+		id:     id,
+		title:  title,
+		width:  width,
+		height: height,
+		input:  input,
+		titleStyle: lipgloss.NewStyle().
+			Background(lipgloss.Color(theme.Neutral)).
+			Foreground(lipgloss.Color(theme.Foreground)).
+			Padding(0, 1),
+		statusStyle:  lipgloss.NewStyle().Foreground(lipgloss.Color(theme.Foreground)).Faint(true),
+		matchStyle:   lipgloss.NewStyle().Background(lipgloss.Color(theme.Tertiary)).Foreground(lipgloss.Color(theme.Neutral)),
+		currentStyle: lipgloss.NewStyle().Background(lipgloss.Color(theme.Primary)).Foreground(lipgloss.Color(theme.Neutral)),
+	}
+	p.setContent(content)
+	// opens at the start, following the output from there while it's at the bottom
+	p.yOffset = 0
+	return p
+}
+
+// setContent replaces the output, keeping the scroll position unless the
+// pager was at the bottom, following the end of the output.
+func (p *pager) setContent(content string) {
+	following := p.yOffset >= p.maxYOffset()
+	p.lines, p.partial, p.matches = nil, "", nil
+	p.addLines(content)
+	p.scroll(following)
+}
+
+// appendContent adds output to the end, following it if the pager was at
+// the bottom.
+func (p *pager) appendContent(chunk string) {
+	following := p.yOffset >= p.maxYOffset()
+	p.addLines(chunk)
+	p.scroll(following)
+}
+
+// addLines adds output to the end, cleaning up and searching only the lines
+// it adds to.
+func (p *pager) addLines(chunk string) {
+	// the unfinished last line is redone with the chunk
+	from := len(p.lines)
+	if p.partial != "" {
+		from--
+	}
+	p.lines = p.lines[:from]
+	text := p.partial + chunk
+	if end := strings.LastIndexByte(text, '\n'); end >= 0 {
+		for _, line := range strings.Split(text[:end], "\n") {
+			p.lines = append(p.lines, pagerLine(line))
+		}
+		text = text[end+1:]
+	}
+	p.partial = text
+	if text != "" {
+		p.lines = append(p.lines, pagerLine(text))
+	}
+	p.searchFrom(from)
+}
+
+// scroll keeps the pager in bounds after the output changes, at the bottom
+// if it was following the end.
+func (p *pager) scroll(following bool) {
+	if following {
+		p.yOffset = p.maxYOffset()
+	}
+	p.yOffset = clamp(p.yOffset, 0, p.maxYOffset())
+}
+
+// pagerLine is a line of output as the pager shows it, without colors.
+func pagerLine(line string) string {
+	return strings.ReplaceAll(ansi.Strip(line), "\t", "    ")
+}
+
+func (p *pager) setSize(width, height int) {
+	p.width, p.height = width, height
+	p.yOffset = clamp(p.yOffset, 0, p.maxYOffset())
+}
+
+// bodyHeight is the number of rows available for output, below the title
+// and above the status line.
+func (p *pager) bodyHeight() int {
+	return max(p.height-2, 1)
+}
+
+// rows splits a line into the rows it takes up on screen.
+func (p *pager) rows(line string) []string {
+	if !p.wrap || p.width <= 0 {
+		return []string{line}
+	}
+	return strings.Split(ansi.Hardwrap(line, p.width, true), "\n")
+}
+
+func (p *pager) rowCount() int {
+	if !p.wrap {
+		return len(p.lines)
+	}
+	var n int
+	for _, line := range p.lines {
+		n += len(p.rows(line))
+	}
+	return n
+}
+
+func (p *pager) maxYOffset() int {
+	return max(p.rowCount()-p.bodyHeight(), 0)
+}
+
+// rowOf returns the screen row of a byte offset within a line.
+func (p *pager) rowOf(line, offset int) int {
+	if !p.wrap {
+		return line
+	}
+	var row int
+	for _, l := range p.lines[:line] {
+		row += len(p.rows(l))
+	}
+	if p.width > 0 {
+		row += ansi.StringWidth(p.lines[line][:offset]) / p.width
+	}
+	return row
+}
+
+// search finds every occurrence of query, ignoring case unless it has capitals.
+func (p *pager) search(query string) {
+	p.query = query
+	p.matches = nil
+	p.searchFrom(0)
+}
+
+// searchFrom redoes the search from line from on, as those lines have changed.
+func (p *pager) searchFrom(from int) {
+	for len(p.matches) > 0 && p.matches[len(p.matches)-1].line >= from {
+		p.matches = p.matches[:len(p.matches)-1]
+	}
+	query := p.query
+	if query == "" {
+		return
+	}
+
+	fold := strings.ToLower(query) == query
+	if fold {
+		query = strings.ToLower(query)
+	}
+	for i := from; i < len(p.lines); i++ {
+		line := p.lines[i]
+		if fold {
+			// lowercasing can change byte lengths outside ASCII, so only fold when it doesn't
+			if lower := strings.ToLower(line); len(lower) == len(line) {
+				line = lower
+			}
+		}
+		for start := 0; ; {
+			idx := strings.Index(line[start:], query)
+			if idx < 0 {
+				break
+			}
+			m := pagerMatch{i, start + idx, start + idx + len(query)}
+			p.matches = append(p.matches, m)
+			start = m.end
+		}
+	}
+	if p.current >= len(p.matches) {
+		p.current = 0
+	}
+}
+
+// jumpFrom moves to the first match at or after the top of the screen.
+func (p *pager) jumpFrom(row int) {
+	for i, m := range p.matches {
+		if p.rowOf(m.line, m.start) >= row {
+			p.jumpTo(i)
+			return
+		}
+	}
+	if len(p.matches) > 0 {
+		p.jumpTo(0)
+	}
+}
+
+// jumpTo scrolls so the i'th match is on screen.
+func (p *pager) jumpTo(i int) {
+	if len(p.matches) == 0 {
+		return
+	}
+	p.current = (i + len(p.matches)) % len(p.matches)
+	m := p.matches[p.current]
+
+	row := p.rowOf(m.line, m.start)
+	if row < p.yOffset || row >= p.yOffset+p.bodyHeight() {
+		p.yOffset = clamp(row-p.bodyHeight()/3, 0, p.maxYOffset())
+	}
+
+	if !p.wrap {
+		line := p.lines[m.line]
+		start, end := ansi.StringWidth(line[:m.start]), ansi.StringWidth(line[:m.end])
+		if start < p.xOffset || end > p.xOffset+p.width {
+			p.xOffset = max(start-p.width/3, 0)
+		}
+	}
+}
+
+// update handles a key press, reporting whether the pager should close.
+func (p *pager) update(msg tea.KeyMsg) (bool, tea.Cmd) {
+	if p.searching {
+		switch msg.Type {
+		case tea.KeyEnter:
+			p.searching = false
+			p.input.Blur()
+			return false, nil
+		case tea.KeyEsc, tea.KeyCtrlC:
+			p.searching = false
+			p.input.Blur()
+			p.search("")
+			return false, nil
+		}
+
+		var cmd tea.Cmd
+		p.input, cmd = p.input.Update(msg)
+		if p.input.Value() != p.query {
+			p.search(p.input.Value())
+			p.jumpFrom(p.yOffset)
+		}
+		return false, cmd
+	}
+
+	switch msg.String() {
+	case "q", "esc", "ctrl+c":
+		return true, nil
+	case "j", "down", "ctrl+j":
+		p.yOffset++
+	case "k", "up", "ctrl+k":
+		p.yOffset--
+	case "pgdown", "f", " ", "ctrl+f":
+		p.yOffset += p.bodyHeight()
+	case "pgup", "b", "ctrl+b":
+		p.yOffset -= p.bodyHeight()
+	case "ctrl+d":
+		p.yOffset += p.bodyHeight() / 2
+	case "ctrl+u":
+		p.yOffset -= p.bodyHeight() / 2
+	case "g", "home":
+		p.yOffset = 0
+	case "G", "end":
+		p.yOffset = p.maxYOffset()
+	case "l", "right":
+		if !p.wrap {
+			p.xOffset += pagerHorizontalStep
+		}
+	case "h", "left":
+		p.xOffset = max(p.xOffset-pagerHorizontalStep, 0)
+	case "0":
+		p.xOffset = 0
+	case "w":
+		p.wrap = !p.wrap
+		p.xOffset = 0
+		if len(p.matches) > 0 {
+			p.jumpTo(p.current)
+		}
+	case "/":
+		p.searching = true
+		p.input.SetValue("")
+		return false, p.input.Focus()
+	case "n":
+		p.jumpTo(p.current + 1)
+	case "N":
+		p.jumpTo(p.current - 1)
+	}
+	p.yOffset = clamp(p.yOffset, 0, p.maxYOffset())
+	return false, nil
+}
+
+// highlight styles the search matches in a line.
+func (p *pager) highlight(i int, line string) string {
+	plain := func(s string) string { return s }
+	if p.lineStyle != nil {
+		style := p.lineStyle(line)
+		plain = func(s string) string {
+			if s == "" {
+				return s
+			}
+			return style.Render(s)
+		}
+	}
+
+	var b strings.Builder
+	last := 0
+	for j, m := range p.matches {
+		if m.line != i {
+			continue
+		}
+		style := p.matchStyle
+		if j == p.current {
+			style = p.currentStyle
+		}
+		b.WriteString(plain(line[last:m.start]))
+		b.WriteString(style.Render(line[m.start:m.end]))
+		last = m.end
+	}
+	b.WriteString(plain(line[last:]))
+	return b.String()
+}
+
+func (p *pager) view() string {
+	var rows []string
+	var row int
+	for i, line := range p.lines {
+		if row >= p.yOffset+p.bodyHeight() {
+			break
+		}
+		for _, r := range p.rows(p.highlight(i, line)) {
+			if row >= p.yOffset && row < p.yOffset+p.bodyHeight() {
+				if !p.wrap {
+					r = ansi.Cut(r, p.xOffset, p.xOffset+p.width)
+				}
+				rows = append(rows, r)
+			}
+			row++
+		}
+	}
+	for len(rows) < p.bodyHeight() {
+		rows = append(rows, "")
+	}
+
+	return p.titleStyle.Render(ansi.Truncate(p.title, max(p.width-2, 0), "…")) + "\n" +
+		strings.Join(rows, "\n") + "\n" +
+		p.status()
+}
+
+func (p *pager) status() string {
+	if p.searching {
+		return p.input.View()
+	}
+
+	var parts []string
+	if total := p.rowCount(); total > 0 {
+		last := min(p.yOffset+p.bodyHeight(), total)
+		parts = append(parts, fmt.Sprintf("%d-%d/%d", p.yOffset+1, last, total))
+	}
+	if p.wrap {
+		parts = append(parts, "wrap")
+	} else if p.xOffset > 0 {
+		parts = append(parts, fmt.Sprintf("col %d", p.xOffset+1))
+	}
+	switch {
+	case p.query != "" && len(p.matches) == 0:
+		parts = append(parts, fmt.Sprintf("/%s: no matches", p.query))
+	case p.query != "":
+		parts = append(parts, fmt.Sprintf("/%s: %d/%d", p.query, p.current+1, len(p.matches)))
+	}
+	parts = append(parts, "/ search • n/N next/prev • w wrap • ←/→ scroll • q close")
+
+	return p.statusStyle.Render(ansi.Truncate(strings.Join(parts, " • "), p.width, "…"))
+}
+
+func clamp(v, low, high int) int {
+	return min(max(v, low), high)
+}
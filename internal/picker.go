@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	pickerHeaderStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color(catppuccin.Secondary))
+	pickerCursorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(catppuccin.Primary))
+	pickerHintStyle   = lipgloss.NewStyle().Faint(true)
+)
+
+type pickerEntry struct {
+	toolchain, command int
+}
+
+// pickerModel lets the user choose which proposed commands go into the config.
+type pickerModel struct {
+	toolchains []toolchain
+	entries    []pickerEntry
+	cursor     int
+	canceled   bool
+}
+
+func newPicker(toolchains []toolchain) pickerModel {
+	var entries []pickerEntry
+	for i, tc := range toolchains {
+		for j := range tc.commands {
+			entries = append(entries, pickerEntry{i, j})
+		}
+	}
+	return pickerModel{toolchains: toolchains, entries: entries}
+}
+
+func (m pickerModel) proposal(e pickerEntry) *proposal {
+	return &m.toolchains[e.toolchain].commands[e.command]
+}
+
+func (m pickerModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m pickerModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+
+	switch keyMsg.String() {
+	case "ctrl+c", "esc", "q":
+		m.canceled = true
+		return m, tea.Quit
+	case "enter":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.entries)-1 {
+			m.cursor++
+		}
+	case " ", "x":
+		p := m.proposal(m.entries[m.cursor])
+		p.selected = !p.selected
+	case "a":
+		// select all unless everything is already selected
+		all := true
+		for _, e := range m.entries {
+			all = all && m.proposal(e).selected
+		}
+		for _, e := range m.entries {
+			m.proposal(e).selected = !all
+		}
+	}
+	return m, nil
+}
+
+func (m pickerModel) View() string {
+	if m.canceled {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("Select commands for panopticon.yaml\n")
+
+	last := -1
+	for i, e := range m.entries {
+		if e.toolchain != last {
+			tc := m.toolchains[e.toolchain]
+			b.WriteString("\n" + pickerHeaderStyle.Render(fmt.Sprintf("%s (%s)", tc.name, tc.file)) + "\n")
+			last = e.toolchain
+		}
+
+		p := m.proposal(e)
+		check := "[ ]"
+		if p.selected {
+			check = "[x]"
+		}
+		line := fmt.Sprintf("%s %s", check, p.cmd.Cmd)
+		if p.comment != "" {
+			line += pickerHintStyle.Render("  # " + p.comment)
+		}
+		if i == m.cursor {
+			b.WriteString(pickerCursorStyle.Render("> ") + line + "\n")
+		} else {
+			b.WriteString("  " + line + "\n")
+		}
+	}
+
+	b.WriteString("\n" + pickerHintStyle.Render("space: toggle • a: toggle all • enter: write config • q: cancel") + "\n")
+	return b.String()
+}
+
+// pickCommands runs the picker and reports whether the user confirmed their selection.
+func pickCommands(toolchains []toolchain) ([]toolchain, bool, error) {
+	final, err := tea.NewProgram(newPicker(toolchains)).Run()
+	if err != nil {
+		return nil, false, err
+	}
+	m := final.(pickerModel)
+	return m.toolchains, !m.canceled, nil
+}
@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// stateDir holds files panopticon writes for other tools, relative to the project root.
+const stateDir = ".panopticon"
+
+const (
+	quickfixFile    = "quickfix.txt"
+	diagnosticsFile = "diagnostics.json"
+)
+
+// problem is an error location as written to the problems files.
+type problem struct {
+	File     string `json:"file"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Command  string `json:"command"`
+}
+
+// problemFiles keeps the problems from each command's latest run and writes
+// them all to a vim quickfix file and a JSON file whenever a run finishes.
+type problemFiles struct {
+	mu        sync.Mutex
+	dir       string
+	byCommand map[int][]problem
+}
+
+func newProblemFiles(dir string) *problemFiles {
+	return &problemFiles{dir: dir, byCommand: make(map[int][]problem)}
+}
+
+// Send writes the problems files when a run finishes.
+func (f *problemFiles) Send(msg tea.Msg) {
+	if res, ok := msg.(result); ok && res.status != Pending {
+		if err := f.update(res, parseLocations(res.output, res.job.Matchers)); err != nil {
+			log.Println("Error writing problems files:", err)
+		}
+	}
+}
+
+// update replaces the problems for a finished run and rewrites the files.
+func (f *problemFiles) update(res result, locations []location) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	problems := make([]problem, 0, len(locations))
+	for _, l := range locations {
+		problems = append(problems, problem{l.file, l.line, l.column, l.severity, l.message, res.job.title()})
+	}
+	f.byCommand[res.job.ID] = problems
+
+	ids := make([]int, 0, len(f.byCommand))
+	for id := range f.byCommand {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var all []problem
+	for _, id := range ids {
+		all = append(all, f.byCommand[id]...)
+	}
+	return f.write(all)
+}
+
+func (f *problemFiles) write(problems []problem) error {
+	if err := os.MkdirAll(f.dir, 0o755); err != nil {
+		return err
+	}
+
+	var quickfix strings.Builder
+	for _, p := range problems {
+		quickfix.WriteString(quickfixLine(p) + "\n")
+	}
+	if err := writeFileAtomic(filepath.Join(f.dir, quickfixFile), []byte(quickfix.String())); err != nil {
+		return err
+	}
+
+	if problems == nil {
+		problems = []problem{}
+	}
+	data, err := json.MarshalIndent(problems, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(f.dir, diagnosticsFile), append(data, '\n'))
+}
+
+// quickfixLine formats a problem for vim's default errorformat, as
+// file:line:col: severity: message [command].
+func quickfixLine(p problem) string {
+	pos := fmt.Sprintf("%s:%d", p.File, p.Line)
+	if p.Column > 0 {
+		pos += fmt.Sprintf(":%d", p.Column)
+	}
+	message := p.Message
+	if !strings.HasPrefix(message, p.Severity) {
+		message = p.Severity + ": " + message
+	}
+	return fmt.Sprintf("%s: %s [%s]", pos, strings.ReplaceAll(message, "\n", " "), p.Command)
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it
+// into place, so readers never see a partial file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// isStateFile reports whether path is inside the state directory, so
+// writing problems files doesn't trigger runs.
+func isStateFile(path string) bool {
+	abs, err := getAbsolutePath(path)
+	if err != nil {
+		return false
+	}
+	dir, err := getAbsolutePath(stateDir)
+	if err != nil {
+		return false
+	}
+	inside, _ := isSubDir(dir, abs)
+	return inside
+}
+
+// severityOf picks a severity from the start of a message, defaulting to error.
+func severityOf(message string) string {
+	lower := strings.ToLower(message)
+	for _, severity := range []string{"warning", "info", "note", "hint"} {
+		if strings.HasPrefix(lower, severity) {
+			return severity
+		}
+	}
+	return "error"
+}
@@ -0,0 +1,264 @@
+package internal
+
+import (
+	"encoding/xml"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Reports are files summarizing the latest run of every command, rewritten
+// whenever a run finishes.
+type Reports struct {
+	JUnit    string `yaml:"junit,omitempty" desc:"Path to write a JUnit XML report to, with each command as a test case."`
+	Markdown string `yaml:"markdown,omitempty" desc:"Path to write a Markdown summary to, such as for a CI job summary."`
+}
+
+// failed output past this many lines is cut from the start in the Markdown summary
+const markdownOutputLines = 100
+
+// reporter keeps each command's latest finished run and writes the reports.
+type reporter struct {
+	mu       sync.Mutex
+	files    Reports
+	commands []Command
+	results  map[int]result
+}
+
+// newReporter returns nil if no reports are configured.
+func newReporter(files Reports, commands []Command) *reporter {
+	if files.JUnit == "" && files.Markdown == "" {
+		return nil
+	}
+	return &reporter{files: files, commands: commands, results: make(map[int]result)}
+}
+
+// Send rewrites the reports when a run finishes.
+func (r *reporter) Send(msg tea.Msg) {
+	res, ok := msg.(result)
+	if !ok || res.status == Pending {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.results[res.job.ID] = res
+	if err := r.write(); err != nil {
+		log.Println("Error writing reports:", err)
+	}
+}
+
+func (r *reporter) write() error {
+	if r.files.JUnit != "" {
+		data, err := junitReport(r.commands, r.results)
+		if err != nil {
+			return err
+		}
+		if err := writeReport(r.files.JUnit, data); err != nil {
+			return err
+		}
+	}
+	if r.files.Markdown != "" {
+		if err := writeReport(r.files.Markdown, []byte(markdownReport(r.commands, r.results))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeReport(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// isReport reports whether path is one of the reports or a temporary file
+// written on the way, so writing them doesn't trigger runs.
+func (r *reporter) isReport(path string) bool {
+	if r == nil {
+		return false
+	}
+	abs, err := getAbsolutePath(path)
+	if err != nil {
+		return false
+	}
+	for _, report := range []string{r.files.JUnit, r.files.Markdown} {
+		if report == "" {
+			continue
+		}
+		reportAbs, err := getAbsolutePath(report)
+		if err != nil {
+			continue
+		}
+		if abs == reportAbs {
+			return true
+		}
+		if filepath.Dir(abs) == filepath.Dir(reportAbs) && strings.HasPrefix(filepath.Base(abs), "."+filepath.Base(reportAbs)+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// isOwnFile reports whether path is a file panopticon writes itself.
+func (m model) isOwnFile(path string) bool {
+	return isStateFile(path) || m.reports.isReport(path)
+}
+
+type junitSuites struct {
+	XMLName  xml.Name     `xml:"testsuites"`
+	Name     string       `xml:"name,attr"`
+	Tests    int          `xml:"tests,attr"`
+	Failures int          `xml:"failures,attr"`
+	Skipped  int          `xml:"skipped,attr"`
+	Time     string       `xml:"time,attr"`
+	Suites   []junitSuite `xml:"testsuite"`
+}
+
+type junitSuite struct {
+	Name      string      `xml:"name,attr"`
+	Tests     int         `xml:"tests,attr"`
+	Failures  int         `xml:"failures,attr"`
+	Skipped   int         `xml:"skipped,attr"`
+	Time      string      `xml:"time,attr"`
+	Timestamp string      `xml:"timestamp,attr,omitempty"`
+	Cases     []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Output  string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr"`
+}
+
+// junitReport describes each command's latest run as a test case, with
+// commands that haven't finished a run skipped.
+func junitReport(commands []Command, results map[int]result) ([]byte, error) {
+	suite := junitSuite{Name: "panopticon", Tests: len(commands)}
+	var total time.Duration
+	var started time.Time
+	for _, cmd := range commands {
+		c := junitCase{Name: cmd.title(), Classname: "panopticon", Time: "0.000"}
+		res, ok := results[cmd.ID]
+		switch {
+		case !ok:
+			suite.Skipped++
+			c.Skipped = &junitSkipped{Message: "not run"}
+		default:
+			total += res.duration
+			if !res.started.IsZero() && (started.IsZero() || res.started.Before(started)) {
+				started = res.started
+			}
+			c.Time = junitSeconds(res.duration)
+			output := ansi.Strip(strings.TrimSpace(res.output))
+			if res.status == Failed {
+				suite.Failures++
+				c.Failure = &junitFailure{
+					Message: fmt.Sprintf("%s failed in %s with exit code %d", cmd.title(), res.duration.Truncate(time.Millisecond), res.exitCode),
+					Type:    fmt.Sprintf("exit code %d", res.exitCode),
+					Output:  output,
+				}
+			} else {
+				c.SystemOut = output
+			}
+		}
+		suite.Cases = append(suite.Cases, c)
+	}
+	suite.Time = junitSeconds(total)
+	if !started.IsZero() {
+		suite.Timestamp = started.Format(time.RFC3339)
+	}
+
+	suites := junitSuites{
+		Name:     "panopticon",
+		Tests:    suite.Tests,
+		Failures: suite.Failures,
+		Skipped:  suite.Skipped,
+		Time:     suite.Time,
+		Suites:   []junitSuite{suite},
+	}
+	data, err := xml.MarshalIndent(suites, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+// markdownReport summarizes each command's latest run in a table, followed
+// by the end of each failed command's output.
+func markdownReport(commands []Command, results map[int]result) string {
+	var b strings.Builder
+	var failed, skipped int
+	var rows, details strings.Builder
+	for _, cmd := range commands {
+		res, ok := results[cmd.ID]
+		name := strings.ReplaceAll(cmd.title(), "|", `\|`)
+		if !ok {
+			skipped++
+			fmt.Fprintf(&rows, "| %s | %s | skipped | | |\n", getEmoji(Pending), name)
+			continue
+		}
+
+		status := "succeeded"
+		if res.status == Failed {
+			failed++
+			status = "failed"
+		}
+		fmt.Fprintf(&rows, "| %s | %s | %s | %s | %d |\n", getEmoji(res.status), name, status, res.duration.Truncate(time.Millisecond), res.exitCode)
+
+		if res.status == Failed {
+			output := ansi.Strip(strings.TrimSpace(res.output))
+			if output == "" {
+				fmt.Fprintf(&details, "\n## %s %s\n\nNo output\n", getEmoji(res.status), cmd.title())
+				continue
+			}
+			lines := strings.Split(output, "\n")
+			if len(lines) > markdownOutputLines {
+				lines = append([]string{fmt.Sprintf("... %d line(s) cut", len(lines)-markdownOutputLines)}, lines[len(lines)-markdownOutputLines:]...)
+			}
+			output = strings.Join(lines, "\n")
+			fence := "```"
+			for strings.Contains(output, fence) {
+				fence += "`"
+			}
+			fmt.Fprintf(&details, "\n## %s %s\n\n%stext\n%s\n%s\n", getEmoji(res.status), cmd.title(), fence, output, fence)
+		}
+	}
+
+	b.WriteString("# panopticon\n\n")
+	fmt.Fprintf(&b, "%d of %d command(s) failed", failed, len(commands))
+	if skipped > 0 {
+		fmt.Fprintf(&b, ", %d not run yet", skipped)
+	}
+	b.WriteString("\n\n")
+	b.WriteString("| | Command | Status | Duration | Exit code |\n")
+	b.WriteString("|---|---|---|---|---|\n")
+	b.WriteString(rows.String())
+	b.WriteString(details.String())
+	return b.String()
+}
@@ -0,0 +1,57 @@
+package internal
+
+import (
+	"context"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReports(t *testing.T) {
+	dir := t.TempDir()
+	commands := []Command{
+		{ID: 0, Name: "ok", Cmd: "echo fine", Shell: "sh"},
+		{ID: 1, Name: "bad", Cmd: "echo '\x1b[31mbroken\x1b[0m <here>' >&2; exit 3", Shell: "sh"},
+		{ID: 2, Name: "never", Cmd: "true", Shell: "sh"},
+	}
+	files := Reports{JUnit: filepath.Join(dir, "reports", "junit.xml"), Markdown: filepath.Join(dir, "summary.md")}
+	m := model{commands: commands, theme: catppuccin, reports: newReporter(files, commands)}
+
+	// the last command never runs
+	s := NewBroadcast(m)
+	runProcess(commands[0], trigger{reason: triggerStart}, s, context.Background())
+	runProcess(commands[1], trigger{reason: triggerStart}, s, context.Background())
+
+	data, err := os.ReadFile(files.JUnit)
+	require.NoError(t, err)
+	var suites junitSuites
+	require.NoError(t, xml.Unmarshal(data, &suites))
+	require.Equal(t, 3, suites.Tests)
+	require.Equal(t, 1, suites.Failures)
+	require.Equal(t, 1, suites.Skipped)
+
+	cases := suites.Suites[0].Cases
+	require.Equal(t, "ok", cases[0].Name)
+	require.Nil(t, cases[0].Failure)
+	require.Equal(t, "fine", cases[0].SystemOut)
+	require.Equal(t, "bad", cases[1].Name)
+	require.Equal(t, "exit code 3", cases[1].Failure.Type)
+	require.Contains(t, cases[1].Failure.Output, "broken <here>")
+	require.NotNil(t, cases[2].Skipped)
+
+	summary, err := os.ReadFile(files.Markdown)
+	require.NoError(t, err)
+	require.Contains(t, string(summary), "1 of 3 command(s) failed, 1 not run yet\n")
+	require.Contains(t, string(summary), "| ✅ | ok | succeeded |")
+	require.Contains(t, string(summary), "| ❌ | bad | failed |")
+	require.Contains(t, string(summary), "| 3 |\n")
+	require.Contains(t, string(summary), "## ❌ bad\n\n```text\nbroken <here>")
+
+	require.True(t, m.isOwnFile(files.JUnit))
+	require.True(t, m.isOwnFile(filepath.Join(dir, "reports", ".junit.xml.12345")))
+	require.False(t, m.isOwnFile(filepath.Join(dir, "reports", "other.xml")))
+	require.Nil(t, newReporter(Reports{}, commands))
+}
@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// collector passes messages on, keeping each command's final result.
+type collector struct {
+	Sender
+	mu      sync.Mutex
+	results map[int]result
+}
+
+func (c *collector) Send(msg tea.Msg) {
+	c.Sender.Send(msg)
+	if res, ok := msg.(result); ok && res.status != Pending {
+		c.mu.Lock()
+		c.results[res.job.ID] = res
+		c.mu.Unlock()
+	}
+}
+
+// RunOnce runs each of the model's commands once, at most jobs at a time,
+// printing their output and then a summary to out, and sending everything on
+// to extra. It returns the exit code: 1 if any command failed, otherwise 0.
+func RunOnce(m model, out io.Writer, ctx context.Context, jobs int, extra ...Sender) int {
+	c := &collector{Sender: NewBroadcast(m, append([]Sender{NewHeadless(m, out)}, extra...)...), results: make(map[int]result)}
+
+	jobs = max(jobs, 1)
+	slots := make(chan struct{}, jobs)
+	var wg sync.WaitGroup
+	// start commands in config order as slots free up
+start:
+	for _, cmd := range m.commands {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+			break start
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			runProcess(cmd, trigger{reason: triggerStart}, c, ctx)
+		}()
+	}
+	wg.Wait()
+	m.hooks.wait()
+
+	return printSummary(out, m.commands, c.results)
+}
+
+// printSummary prints each command's status and duration, returning 1 if any
+// failed or didn't run.
+func printSummary(out io.Writer, commands []Command, results map[int]result) int {
+	fmt.Fprintln(out)
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	var failed int
+	for _, cmd := range commands {
+		res, ok := results[cmd.ID]
+		switch {
+		case !ok:
+			failed++
+			fmt.Fprintf(w, "%s\t%s\tskipped\t\n", getEmoji(Pending), cmd.title())
+		case res.status == Failed:
+			failed++
+			fmt.Fprintf(w, "%s\t%s\tfailed\t%s\n", getEmoji(res.status), cmd.title(), res.duration.Truncate(time.Millisecond))
+		default:
+			fmt.Fprintf(w, "%s\t%s\tsucceeded\t%s\n", getEmoji(res.status), cmd.title(), res.duration.Truncate(time.Millisecond))
+		}
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\n%d of %d command(s) failed\n", failed, len(commands))
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,211 @@
+package internal
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// schemaFiles maps each schema name to the file it is written to.
+var schemaFiles = map[string]string{
+	"project": "panopticon.schema.json",
+	"user":    "panopticon_config.schema.json",
+	"events":  "panopticon_events.schema.json",
+}
+
+var schemaRoots = map[string]struct {
+	value       any
+	description string
+}{
+	"project": {CommandConfig{}, "Panopticon project config (panopticon.yaml)"},
+	"user":    {Config{}, "Panopticon user config ($XDG_CONFIG_HOME/panopticon/config.yaml)"},
+	"events":  {Event{}, "Panopticon event stream (--events json), one event per line"},
+}
+
+type schema struct {
+	Schema               string      `json:"$schema,omitempty"`
+	Ref                  string      `json:"$ref,omitempty"`
+	Description          string      `json:"description,omitempty"`
+	Type                 string      `json:"type,omitempty"`
+	Enum                 []string    `json:"enum,omitempty"`
+	Pattern              string      `json:"pattern,omitempty"`
+	Format               string      `json:"format,omitempty"`
+	Properties           *properties `json:"properties,omitempty"`
+	AdditionalProperties any         `json:"additionalProperties,omitempty"`
+	Items                *schema     `json:"items,omitempty"`
+	Defs                 *properties `json:"$defs,omitempty"`
+}
+
+// properties keeps schemas in struct field order when marshalled.
+type properties struct {
+	names   []string
+	schemas map[string]*schema
+}
+
+func (p *properties) set(name string, s *schema) {
+	if p.schemas == nil {
+		p.schemas = make(map[string]*schema)
+	}
+	if _, ok := p.schemas[name]; !ok {
+		p.names = append(p.names, name)
+	}
+	p.schemas[name] = s
+}
+
+func (p *properties) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, name := range p.names {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(name)
+		value, err := json.Marshal(p.schemas[name])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Schema generates the JSON schema for the "project" or "user" config, or
+// for "events" in the event stream.
+func Schema(name string) ([]byte, error) {
+	root, ok := schemaRoots[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown schema %q (expected one of %s)", name, strings.Join(SchemaNames(), ", "))
+	}
+
+	g := schemaGenerator{defs: &properties{}}
+	s := g.generate(reflect.TypeOf(root.value), "", "")
+	// inline the root type rather than referencing it
+	if def, ok := g.defs.schemas[reflect.TypeOf(root.value).Name()]; ok {
+		delete(g.defs.schemas, reflect.TypeOf(root.value).Name())
+		g.defs.names = g.defs.names[1:]
+		s = def
+	}
+	s.Schema = schemaDraft
+	s.Description = root.description
+	if len(g.defs.names) > 0 {
+		s.Defs = g.defs
+	}
+
+	out, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(out, '\n'), nil
+}
+
+// SchemaNames lists the schemas that can be generated.
+func SchemaNames() []string {
+	names := make([]string, 0, len(schemaRoots))
+	for name := range schemaRoots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SchemaFile returns the file name a schema is conventionally written to.
+func SchemaFile(name string) string {
+	return schemaFiles[name]
+}
+
+type schemaGenerator struct {
+	defs *properties
+}
+
+func (g schemaGenerator) generate(t reflect.Type, description, enum string) *schema {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return &schema{
+			Description: description,
+			Type:        "string",
+			Pattern:     `^([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+$`,
+		}
+	}
+	if t == reflect.TypeOf(time.Time{}) {
+		return &schema{Description: description, Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.generate(t.Elem(), description, enum)
+	case reflect.Struct:
+		name := t.Name()
+		if _, ok := g.defs.schemas[name]; !ok {
+			// reserve the name first so recursive types terminate
+			g.defs.set(name, nil)
+			g.defs.set(name, g.object(t))
+		}
+		return &schema{Ref: "#/$defs/" + name, Description: description}
+	case reflect.Slice, reflect.Array:
+		return &schema{Description: description, Type: "array", Items: g.generate(t.Elem(), "", enum)}
+	case reflect.Map:
+		return &schema{Description: description, Type: "object", AdditionalProperties: g.generate(t.Elem(), "", enum)}
+	case reflect.Bool:
+		return &schema{Description: description, Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &schema{Description: description, Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &schema{Description: description, Type: "number"}
+	default:
+		return &schema{Description: description, Type: "string", Enum: schemaEnum(enum)}
+	}
+}
+
+func (g schemaGenerator) object(t reflect.Type) *schema {
+	props := &properties{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("yaml")
+		if !ok {
+			tag = f.Tag.Get("json")
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			inline := g.object(f.Type)
+			for _, name := range inline.Properties.names {
+				props.set(name, inline.Properties.schemas[name])
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		props.set(name, g.generate(f.Type, f.Tag.Get("desc"), f.Tag.Get("enum")))
+	}
+	return &schema{Type: "object", Properties: props, AdditionalProperties: false}
+}
+
+func schemaEnum(enum string) []string {
+	switch enum {
+	case "theme":
+		names := append([]string{"default"}, strings.Split(presetNames(), ", ")...)
+		return names
+	case "layout":
+		return layouts
+	case "event":
+		return eventTypes
+	case "notify_on":
+		return notifyTransitions
+	case "notify_with":
+		return notifyMethods
+	default:
+		return nil
+	}
+}
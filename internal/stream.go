@@ -0,0 +1,193 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// how many recent events are kept for clients resuming a stream
+	eventBacklog = 1000
+	// events queued for a client before it's dropped as too slow
+	subscriberBuffer = 256
+	// how often an idle stream sends a comment so proxies keep it open
+	keepaliveInterval = 30 * time.Second
+	// sent first when events a client resumes after are no longer kept, so
+	// it fetches the current state again
+	streamReset = "reset"
+)
+
+// eventHub keeps recent events and passes new ones to each stream.
+type eventHub struct {
+	mu      sync.Mutex
+	backlog []Event
+	// the last event dropped for the backlog's length
+	dropped     int
+	subscribers map[chan Event]bool
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: make(map[chan Event]bool)}
+}
+
+func (h *eventHub) publish(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if e.Type == eventRunFinished && e.Command != nil {
+		h.dropOutput(e.Command.ID)
+	}
+	h.backlog = append(h.backlog, e)
+	if len(h.backlog) > eventBacklog {
+		h.dropped = h.backlog[len(h.backlog)-eventBacklog-1].Seq
+		h.backlog = h.backlog[len(h.backlog)-eventBacklog:]
+	}
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// too slow, it can reconnect and resume from its last event
+			delete(h.subscribers, ch)
+			close(ch)
+		}
+	}
+}
+
+// dropOutput drops a command's output events from the backlog once its run
+// finishes, since the run_finished event has all of it.
+func (h *eventHub) dropOutput(id int) {
+	kept := h.backlog[:0]
+	for _, e := range h.backlog {
+		if e.Type != eventOutput || e.Command == nil || e.Command.ID != id {
+			kept = append(kept, e)
+		}
+	}
+	clear(h.backlog[len(kept):])
+	h.backlog = kept
+}
+
+// subscribe returns the kept events after seq since, if since is positive,
+// whether any after it are no longer kept, and a channel of those that
+// follow, closed if the stream falls behind.
+func (h *eventHub) subscribe(since int) ([]Event, bool, chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	var past []Event
+	gap := since > 0 && since < h.dropped
+	if since > 0 {
+		for _, e := range h.backlog {
+			if e.Seq > since {
+				past = append(past, e)
+			}
+		}
+	}
+	ch := make(chan Event, subscriberBuffer)
+	h.subscribers[ch] = true
+	return past, gap, ch
+}
+
+func (h *eventHub) unsubscribe(ch chan Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.subscribers[ch] {
+		delete(h.subscribers, ch)
+		close(ch)
+	}
+}
+
+// streamEvents sends events as Server-Sent Events, each with its sequence
+// number as the id. Repeated command parameters limit events to those
+// commands, and a since parameter or Last-Event-ID header first replays
+// the kept events after that sequence number, after a reset event if some
+// are no longer kept.
+func (srv *Server) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, apiError{"streaming isn't supported"})
+		return
+	}
+
+	var only map[int]bool
+	for _, name := range r.URL.Query()["command"] {
+		cmd, ok := srv.findCommand(name)
+		if !ok {
+			writeJSON(w, http.StatusNotFound, apiError{fmt.Sprintf("no command %q", name)})
+			return
+		}
+		if only == nil {
+			only = make(map[int]bool)
+		}
+		only[cmd.ID] = true
+	}
+
+	since := r.Header.Get("Last-Event-ID")
+	if s := r.URL.Query().Get("since"); s != "" {
+		since = s
+	}
+	var sinceSeq int
+	if since != "" {
+		var err error
+		if sinceSeq, err = strconv.Atoi(since); err != nil {
+			writeJSON(w, http.StatusBadRequest, apiError{fmt.Sprintf("invalid sequence number %q", since)})
+			return
+		}
+	}
+
+	past, gap, ch := srv.hub.subscribe(sinceSeq)
+	defer srv.hub.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	send := func(e Event) error {
+		if only != nil && (e.Command == nil || !only[e.Command.ID]) && e.Type != eventConfigLoaded {
+			return nil
+		}
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.Seq, e.Type, data); err != nil {
+			return err
+		}
+		flusher.Flush()
+		return nil
+	}
+
+	if gap {
+		if _, err := fmt.Fprintf(w, "event: %s\ndata: {}\n\n", streamReset); err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+	for _, e := range past {
+		if send(e) != nil {
+			return
+		}
+	}
+
+	keepalive := time.NewTicker(keepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-ch:
+			if !ok || send(e) != nil {
+				return
+			}
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// readEvents reads n events from a Server-Sent Events stream.
+func readEvents(t *testing.T, r *bufio.Reader, n int) []Event {
+	var events []Event
+	var id string
+	for len(events) < n {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "id: "):
+			id = strings.TrimPrefix(line, "id: ")
+		case strings.HasPrefix(line, "data: "):
+			var e Event
+			require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &e))
+			require.Equal(t, strconv.Itoa(e.Seq), id)
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func TestStreamEvents(t *testing.T) {
+	build := Command{ID: 0, Name: "build", Cmd: "go build"}
+	test := Command{ID: 1, Name: "test", Cmd: "go test"}
+	srv := NewServer(model{commands: []Command{build, test}}, nil)
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	res, err := http.Get(ts.URL + "/events?command=test")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	srv.Send(result{status: Pending, job: build, trigger: trigger{reason: triggerManual}})
+	srv.Send(result{status: Pending, job: test, trigger: trigger{triggerChange, []string{"main_test.go"}}})
+	srv.Send(outputMsg{id: 1, chunk: "ok\n"})
+	srv.Send(result{status: Succeeded, job: test, output: "ok\n"})
+
+	events := readEvents(t, bufio.NewReader(res.Body), 3)
+	require.Equal(t, eventRunStarted, events[0].Type)
+	require.Equal(t, "test", events[0].Command.Name)
+	require.Equal(t, []string{"main_test.go"}, events[0].Files)
+	require.Equal(t, eventOutput, events[1].Type)
+	require.Equal(t, eventRunFinished, events[2].Type)
+	require.Equal(t, 0, *events[2].ExitCode)
+
+	// resuming replays the kept events after the last one seen, without the
+	// output of finished runs
+	req, err := http.NewRequest("GET", ts.URL+"/events", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "2")
+	resumed, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resumed.Body.Close()
+	events = readEvents(t, bufio.NewReader(resumed.Body), 2)
+	require.Equal(t, []int{3, 5}, []int{events[0].Seq, events[1].Seq})
+
+	res, err = http.Get(ts.URL + "/events?command=nope")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, err = http.Get(ts.URL + "/events?since=abc")
+	require.NoError(t, err)
+	res.Body.Close()
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestEventHub(t *testing.T) {
+	h := newEventHub()
+	test := &EventCommand{ID: 1, Name: "test"}
+	h.publish(Event{Seq: 1, Type: eventRunStarted, Command: test})
+	h.publish(Event{Seq: 2, Type: eventOutput, Command: test})
+	past, gap, _ := h.subscribe(1)
+	require.False(t, gap)
+	require.Len(t, past, 1)
+
+	// finished runs keep their output in run_finished
+	h.publish(Event{Seq: 3, Type: eventRunFinished, Command: test})
+	past, _, _ = h.subscribe(1)
+	require.Equal(t, []Event{{Seq: 3, Type: eventRunFinished, Command: test}}, past)
+
+	for seq := 4; seq <= eventBacklog+3; seq++ {
+		h.publish(Event{Seq: seq, Type: eventChange})
+	}
+	past, gap, _ = h.subscribe(3)
+	require.False(t, gap)
+	require.Len(t, past, eventBacklog)
+	past, gap, _ = h.subscribe(1)
+	require.True(t, gap, "run_started 1 is no longer kept")
+	require.Equal(t, 4, past[0].Seq)
+}
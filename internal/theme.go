@@ -1,11 +1,22 @@
 package internal
 
+var themePresets = map[string]Theme{
+	"catppuccin": catppuccin,
+	"dracula":    dracula,
+	"gruvbox":    gruvbox,
+	"nord":       nord,
+	"solarized":  solarized,
+	"tokyonight": tokyonight,
+}
+
 var catppuccin = Theme{
 	Foreground: "#f2d5cf",
 	Primary:    "#f4b8e4",
 	Secondary:  "#8caaee",
 	Tertiary:   "#ca9ee6",
 	Neutral:    "#414559",
+	Added:      "#a6d189",
+	Removed:    "#e78284",
 }
 
 var dracula = Theme{
@@ -14,6 +25,8 @@ var dracula = Theme{
 	Secondary:  "#50fa7b",
 	Tertiary:   "#ff79c6",
 	Neutral:    "#282a36",
+	Added:      "#50fa7b",
+	Removed:    "#ff5555",
 }
 
 var nord = Theme{
@@ -22,6 +35,8 @@ var nord = Theme{
 	Secondary:  "#88c0d0",
 	Tertiary:   "#5e81ac",
 	Neutral:    "#3b4252",
+	Added:      "#a3be8c",
+	Removed:    "#bf616a",
 }
 
 var gruvbox = Theme{
@@ -30,6 +45,8 @@ var gruvbox = Theme{
 	Secondary:  "#b8bb26",
 	Tertiary:   "#83a598",
 	Neutral:    "#3c3836",
+	Added:      "#b8bb26",
+	Removed:    "#fb4934",
 }
 
 var solarized = Theme{
@@ -38,6 +55,8 @@ var solarized = Theme{
 	Secondary:  "#2aa198",
 	Tertiary:   "#b58900",
 	Neutral:    "#073642",
+	Added:      "#859900",
+	Removed:    "#dc322f",
 }
 
 var tokyonight = Theme{
@@ -46,4 +65,6 @@ var tokyonight = Theme{
 	Secondary:  "#a9dc76",
 	Tertiary:   "#ff9e64",
 	Neutral:    "#1f1f28",
+	Added:      "#9ece6a",
+	Removed:    "#f7768e",
 }
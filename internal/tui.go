@@ -5,8 +5,10 @@ import (
 	"log"
 	"os"
 	"sort"
+	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/bubbles/spinner"
@@ -42,13 +44,48 @@ func (m model) Init() tea.Cmd {
 
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	var command tea.Cmd
+	if m.pager != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			closed, cmd := m.pager.update(msg)
+			if closed {
+				m.pager = nil
+			}
+			return m, cmd
+		case tea.WindowSizeMsg:
+			m.pager.setSize(msg.Width, msg.Height)
+			if m.historyView != nil {
+				m.historyView.setSize(msg.Width, msg.Height)
+			}
+			return m, nil
+		}
+	} else if m.historyView != nil {
+		switch msg := msg.(type) {
+		case tea.KeyMsg:
+			closed, run, diff := m.historyView.update(msg)
+			switch {
+			case closed:
+				m.historyView = nil
+			case run != nil:
+				// past runs don't change, so the pager never gets live output
+				m.pager = newPager(-1, runTitle(*run), getStatus(run.res)+"\n"+run.res.output, m.theme, m.historyView.width, m.historyView.height)
+			case len(diff) == 2:
+				m.pager = newDiffPager(diff[0], diff[1], m.theme, m.historyView.width, m.historyView.height)
+			}
+			return m, nil
+		case tea.WindowSizeMsg:
+			m.historyView.setSize(msg.Width, msg.Height)
+			return m, nil
+		}
+	}
+
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
-		switch msg.Type {
-		case tea.KeyCtrlC, tea.KeyEsc:
+		switch {
+		case key.Matches(msg, m.keys.quit):
 			m.quitting = true
 			command = tea.Sequence(m.closeWatchers, tea.Quit)
-		case tea.KeyEnter:
+		case key.Matches(msg, m.keys.toggle) && !m.hasPane():
 			i, _ := m.list.SelectedItem().(item)
 			// toggle viewport
 			if m.currentSelected == i.id && (m.currentViewport != nil || i.running) {
@@ -79,30 +116,64 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					running:         i.running,
 				})
 			}
-		case tea.KeyCtrlJ, tea.KeyCtrlDown:
-			m.currentViewport.LineDown(2)
-		case tea.KeyCtrlK, tea.KeyCtrlUp:
-			m.currentViewport.LineUp(2)
-		case tea.KeyRunes:
-			if msg.String() == "q" {
-				command = tea.Sequence(m.closeWatchers, tea.Quit)
-			} else if msg.String() == "r" {
-				i, _ := m.list.SelectedItem().(item)
-				m.currentViewport = nil
-				command = func() tea.Msg {
-					m.list.SetItem(i.id, item{
-						title:           i.title,
-						body:            i.body,
-						emoji:           i.emoji,
-						id:              i.id,
-						viewport:        nil,
-						viewportVisible: false,
-						running:         true,
-					})
-					log.Println("Executing command:", i.title)
-					executeCommand(m, i.id)
-					return nil
-				}
+		case key.Matches(msg, m.keys.pager):
+			i, ok := m.list.SelectedItem().(item)
+			if ok {
+				width, height := terminalSize()
+				m.pager = newPager(i.id, i.title, i.body, m.theme, width, height)
+				return m, nil
+			}
+		case key.Matches(msg, m.keys.history):
+			i, ok := m.list.SelectedItem().(item)
+			if ok {
+				width, height := terminalSize()
+				m.historyView = newHistoryView(i.id, i.title, m.history[i.id], m.theme, width, height)
+				return m, nil
+			}
+		case key.Matches(msg, m.keys.diff):
+			i, ok := m.list.SelectedItem().(item)
+			if runs := m.history[i.id]; ok && len(runs) >= 2 {
+				width, height := terminalSize()
+				m.pager = newDiffPager(runs[len(runs)-2], runs[len(runs)-1], m.theme, width, height)
+				return m, nil
+			}
+		case key.Matches(msg, m.keys.nextError):
+			m.cycleError(1)
+		case key.Matches(msg, m.keys.prevError):
+			m.cycleError(-1)
+		case key.Matches(msg, m.keys.openError):
+			locations := m.selectedLocations()
+			if m.errorIndex >= 0 && m.errorIndex < len(locations) {
+				command = openLocation(locations[m.errorIndex])
+			}
+		case key.Matches(msg, m.keys.scrollDown):
+			if m.hasPane() {
+				m.pane.LineDown(2)
+			} else if m.currentViewport != nil {
+				m.currentViewport.LineDown(2)
+			}
+		case key.Matches(msg, m.keys.scrollUp):
+			if m.hasPane() {
+				m.pane.LineUp(2)
+			} else if m.currentViewport != nil {
+				m.currentViewport.LineUp(2)
+			}
+		case key.Matches(msg, m.keys.run):
+			i, _ := m.list.SelectedItem().(item)
+			m.currentViewport = nil
+			command = func() tea.Msg {
+				m.list.SetItem(i.id, item{
+					title:           i.title,
+					body:            i.body,
+					emoji:           i.emoji,
+					id:              i.id,
+					viewport:        nil,
+					viewportVisible: false,
+					running:         true,
+				})
+				log.Println("Executing command:", i.title)
+				executeCommand(m, i.id)
+				return nil
 			}
 		}
 	case tea.WindowSizeMsg:
@@ -120,13 +191,28 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		status := getStatus(msg)
 		log.Print(status)
 		m.results[msg.job.ID] = msg
+		body := status + "\n" + msg.output
+		if msg.status == Pending {
+			m.live[msg.job.ID] = &strings.Builder{}
+			m.live[msg.job.ID].WriteString(body)
+		} else {
+			delete(m.live, msg.job.ID)
+		}
+		locations := parseLocations(msg.output, msg.job.Matchers)
+		m.locations[msg.job.ID] = locations
+		if msg.job.ID == m.errorSelected {
+			m.errorIndex = -1
+		}
 		m.list.SetItem(msg.job.ID, item{
 			id:      msg.job.ID,
-			title:   msg.job.Cmd,
-			body:    status + "\n" + msg.output,
+			title:   msg.job.title(),
+			body:    body,
 			emoji:   getEmoji(msg.status),
 			running: msg.status == Pending,
 		})
+		if m.pager != nil && m.pager.id == msg.job.ID {
+			m.pager.setContent(body)
+		}
 
 		var completed int
 		for _, res := range m.results {
@@ -137,15 +223,55 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		percent := float64(completed) / float64(len(m.commands))
 
 		command = m.progress.SetPercent(percent)
+		if msg.status != Pending {
+			m.recordRun(msg)
+		}
+	case notificationMsg:
+		m.notifier.write(string(msg))
+	case pausedMsg:
+		m.list.Title = "Commands"
+		if msg {
+			m.list.Title = "Commands (watching paused)"
+		}
+	case editorFinishedMsg:
+		if msg.err != nil {
+			log.Println("Error opening editor:", msg.err)
+		}
+	case outputMsg:
+		live, ok := m.live[msg.id]
+		if !ok || !msg.of(m.results[msg.id]) {
+			break
+		}
+		live.WriteString(msg.chunk)
+		i, _ := m.list.Items()[msg.id].(item)
+		i.body = live.String()
+		m.list.SetItem(msg.id, i)
+		if m.pager != nil && m.pager.id == msg.id {
+			m.pager.appendContent(msg.chunk)
+		}
 	}
 
 	var listUpdateCmd tea.Cmd
 	m.list, listUpdateCmd = m.list.Update(msg)
 	m = setSizes(m)
+	if m.hasPane() && m.paneChanged(msg) {
+		m.refreshPane()
+	}
+	if i, ok := m.list.SelectedItem().(item); ok && i.id != m.errorSelected {
+		m.errorSelected = i.id
+		m.errorIndex = -1
+	}
 	return m, tea.Batch(listUpdateCmd, command)
 }
 
 func (m model) View() string {
+	if m.pager != nil {
+		return m.pager.view()
+	}
+	if m.historyView != nil {
+		return m.historyView.view()
+	}
+
 	m = setSizes(m)
 	s := "\n" +
 		m.spinner.View() + " Watching 👀...\n\n"
@@ -162,7 +288,14 @@ func (m model) View() string {
 		return items[i].job.ID < items[j].job.ID
 	})
 
-	s += m.list.View() + "\n"
+	if m.hasPane() {
+		s += m.paneView() + "\n"
+	} else {
+		s += m.list.View() + "\n"
+	}
+	if errLine := m.errorView(); errLine != "" {
+		s += errLine + "\n"
+	}
 
 	if m.quitting {
 		s += "\n"
@@ -190,7 +323,7 @@ func getEmoji(success Status) string {
 func getDefaultItems(items []Command) []list.Item {
 	var listItems []list.Item
 	for _, i := range items {
-		listItems = append(listItems, item{title: i.Cmd, body: "Waiting to run", id: i.ID, running: true})
+		listItems = append(listItems, item{title: i.title(), body: "Waiting to run", id: i.ID, running: true})
 	}
 	return listItems
 }
@@ -199,14 +332,23 @@ func getStatus(res result) string {
 	d := time.Duration.Truncate(res.duration, time.Microsecond)
 	switch res.status {
 	case Succeeded:
-		return fmt.Sprintf("%s %s finished in %s\n", getEmoji(res.status), res.job.Cmd, d)
+		return fmt.Sprintf("%s %s finished in %s\n", getEmoji(res.status), res.job.title(), d)
 	case Failed:
-		return fmt.Sprintf("%s %s failed in %s\n", getEmoji(res.status), res.job.Cmd, d)
+		return fmt.Sprintf("%s %s failed in %s\n", getEmoji(res.status), res.job.title(), d)
 	default:
-		return fmt.Sprintf("%s %s running...\n", getEmoji(res.status), res.job.Cmd)
+		return fmt.Sprintf("%s %s running...\n", getEmoji(res.status), res.job.title())
 	}
 }
 
+// terminalSize returns the size of the terminal, or 80x24 when it's unknown.
+func terminalSize() (int, int) {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil || width <= 0 || height <= 0 {
+		return 80, 24
+	}
+	return width, height
+}
+
 func setSizes(m model) model {
 	// Get frame dimensions
 	h, v := mainStyle.GetFrameSize()
@@ -228,7 +370,21 @@ func setSizes(m model) model {
 	// Set components sizes
 	m.progress.Width = usableWidth - offset
 
-	if m.currentViewport != nil {
+	if m.hasPane() {
+		// List and pane share the space, each pane border taking a row or column either side
+		ph, pv := paneStyle.GetFrameSize()
+		if m.layout == layoutSplit {
+			listWidth := usableWidth * 2 / 5
+			m.list.SetSize(listWidth, usableHeight-padding)
+			m.pane.Width = max(usableWidth-listWidth-ph-padding, 0)
+			m.pane.Height = max(usableHeight-padding-pv, 0)
+		} else {
+			listHeight := (usableHeight - padding) / 3
+			m.list.SetSize(usableWidth-padding, listHeight)
+			m.pane.Width = max(usableWidth-padding-ph, 0)
+			m.pane.Height = max(usableHeight-padding-listHeight-pv, 0)
+		}
+	} else if m.currentViewport != nil {
 		// Split height between list and viewport
 		i, _ := m.list.SelectedItem().(item)
 		viewportHeight := min(countLines(i.body), usableHeight-15)
@@ -1,9 +1,14 @@
 package internal
 
 import (
+	"fmt"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/gobwas/glob"
 	"github.com/stretchr/testify/require"
 )
@@ -61,13 +66,191 @@ func TestNewModel(t *testing.T) {
 
 	// Test that the NewModel function returns a model
 	cancel := func() {}
-	m := NewModel(cancel, glob.MustCompile("*"), "")
+	m := NewModel(cancel, glob.MustCompile("*"), Options{})
 
 	require.NotNil(t, m)
 
 	// Test that the model filters for the pattern
-	m = NewModel(cancel, glob.MustCompile("*hello world*"), "")
+	m = NewModel(cancel, glob.MustCompile("*hello world*"), Options{})
 
 	require.Len(t, m.commands, 1)
 	require.Equal(t, "echo 'hello world'", m.commands[0].Cmd)
 }
+
+func TestPagerSearch(t *testing.T) {
+	var content string
+	for i := range 100 {
+		content += fmt.Sprintf("line %d\n", i)
+	}
+	content += "an Error here, and another error " + strings.Repeat("x", 100) + " error\n"
+
+	p := newPager(0, "test", content, catppuccin, 40, 12)
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("/")})
+	for _, r := range "error" {
+		p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+	}
+	p.update(tea.KeyMsg{Type: tea.KeyEnter})
+
+	// lowercase queries ignore case
+	require.Len(t, p.matches, 3)
+	require.Equal(t, 100, p.matches[0].line)
+	require.Contains(t, p.view(), "/error: 1/3")
+	require.Equal(t, p.maxYOffset(), p.yOffset)
+
+	// the last match is off to the right, so the pager scrolls to it
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("N")})
+	require.Equal(t, 2, p.current)
+	require.Positive(t, p.xOffset)
+
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("g")})
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("0")})
+	require.Equal(t, 0, p.yOffset)
+	require.Contains(t, p.view(), "line 0")
+
+	// wrapping spreads the long line over several rows
+	rows := p.rowCount()
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w")})
+	require.Greater(t, p.rowCount(), rows)
+	require.Equal(t, 0, p.xOffset)
+
+	closed, _ := p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("q")})
+	require.True(t, closed)
+}
+
+func TestPagerFollows(t *testing.T) {
+	// output that fits keeps being followed once it outgrows the screen
+	p := newPager(0, "test", "⏳ test running...\n", catppuccin, 40, 5)
+	require.Equal(t, 0, p.yOffset)
+	content := "⏳ test running...\n"
+	for i := range 10 {
+		content += fmt.Sprintf("line %d\n", i)
+		p.setContent(content)
+	}
+	require.Equal(t, p.maxYOffset(), p.yOffset)
+	require.Contains(t, p.view(), "line 9")
+
+	// until it's scrolled up
+	p.update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("k")})
+	p.setContent(content + "line 10\n")
+	require.Less(t, p.yOffset, p.maxYOffset())
+
+	// long output opens at the start
+	p = newPager(0, "test", content, catppuccin, 40, 5)
+	require.Equal(t, 0, p.yOffset)
+}
+
+func TestPagerAppend(t *testing.T) {
+	chunks := []string{"ok a\nFA", "IL b\n\x1b[3", "1mFAIL\x1b[0m c\tx\n", "ok d"}
+	whole := newPager(0, "test", strings.Join(chunks, ""), catppuccin, 40, 5)
+	whole.search("fail")
+
+	p := newPager(0, "test", "", catppuccin, 40, 5)
+	p.search("fail")
+	for _, chunk := range chunks {
+		p.appendContent(chunk)
+	}
+	require.Equal(t, []string{"ok a", "FAIL b", "FAIL c    x", "ok d"}, p.lines)
+	require.Equal(t, whole.lines, p.lines)
+	require.Equal(t, whole.matches, p.matches)
+}
+
+func TestSplitLayoutLiveOutput(t *testing.T) {
+	err := os.WriteFile(commandFile, []byte(sampleConfig), 0o644)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+	}()
+
+	m := NewModel(func() {}, glob.MustCompile("*"), Options{Layout: layoutSplit})
+	require.True(t, m.hasPane())
+	m.pane.Width, m.pane.Height = 40, 3
+
+	update := func(msg tea.Msg) {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+
+	update(result{status: Pending, job: m.commands[0]})
+	for i := range 5 {
+		update(outputMsg{id: 0, chunk: fmt.Sprintf("line %d\n", i)})
+	}
+
+	// the pane follows the output as it arrives
+	require.Contains(t, m.pane.View(), "line 4")
+	require.NotContains(t, m.pane.View(), "line 0")
+
+	// moving the selection shows the other command's output
+	update(tea.KeyMsg{Type: tea.KeyDown})
+	require.Equal(t, 1, m.paneSelected)
+	require.Contains(t, m.pane.View(), "Waiting to run")
+
+	// finishing replaces the live output
+	update(result{status: Succeeded, job: m.commands[0], output: "done"})
+	update(outputMsg{id: 0, chunk: "late\n"})
+	update(tea.KeyMsg{Type: tea.KeyUp})
+	require.Contains(t, m.pane.View(), "done")
+	require.NotContains(t, m.pane.View(), "late")
+
+	// spinner frames and other commands' output leave the pane alone
+	m.pane.SetContent("unchanged")
+	update(spinner.TickMsg{})
+	update(result{status: Pending, job: m.commands[1]})
+	update(outputMsg{id: 1, chunk: "other\n"})
+	require.Contains(t, m.pane.View(), "unchanged")
+	update(outputMsg{id: 0, chunk: "late\n"})
+	update(result{status: Pending, job: m.commands[0]})
+	require.Contains(t, m.pane.View(), "running")
+
+	// output from the run the next one replaced isn't added to it
+	previous := time.Now()
+	update(result{status: Pending, job: m.commands[0], started: previous.Add(time.Second)})
+	update(outputMsg{id: 0, chunk: "stale\n", started: previous})
+	update(outputMsg{id: 0, chunk: "fresh\n", started: previous.Add(time.Second)})
+	require.Contains(t, m.pane.View(), "fresh")
+	require.NotContains(t, m.pane.View(), "stale")
+}
+
+func TestRunHistory(t *testing.T) {
+	err := os.WriteFile(commandFile, []byte(sampleConfig), 0o644)
+	require.NoError(t, err)
+	defer func() {
+		_ = os.RemoveAll(commandFile)
+	}()
+
+	m := NewModel(func() {}, glob.MustCompile("*"), Options{})
+	update := func(msg tea.Msg) {
+		updated, _ := m.Update(msg)
+		m = updated.(model)
+	}
+	press := func(keys string) {
+		update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(keys)})
+	}
+
+	update(result{status: Failed, job: m.commands[0], output: "flaky failure", trigger: trigger{triggerChange, []string{"main.go"}}})
+	for range historyLimit {
+		update(result{status: Succeeded, job: m.commands[0], output: "ok", trigger: trigger{reason: triggerManual}})
+	}
+	require.Len(t, m.history[0], historyLimit)
+	require.Equal(t, historyLimit+1, m.history[0][historyLimit-1].number)
+
+	update(result{status: Failed, job: m.commands[1], output: "flaky failure", trigger: trigger{triggerChange, []string{"main.go"}}})
+	update(result{status: Succeeded, job: m.commands[1], output: "ok", trigger: trigger{reason: triggerManual}})
+
+	// newest first, so the failure is second
+	update(tea.KeyMsg{Type: tea.KeyDown})
+	press("H")
+	require.NotNil(t, m.historyView)
+	require.Contains(t, m.View(), "#2 ✅")
+	require.Contains(t, m.View(), "change: main.go")
+
+	press("j")
+	update(tea.KeyMsg{Type: tea.KeyEnter})
+	require.NotNil(t, m.pager)
+	require.Contains(t, m.View(), "flaky failure")
+
+	press("q")
+	require.Nil(t, m.pager)
+	require.NotNil(t, m.historyView)
+	press("q")
+	require.Nil(t, m.historyView)
+}
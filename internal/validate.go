@@ -0,0 +1,373 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+	"gopkg.in/yaml.v3"
+)
+
+// Diagnostic is a problem found in a config file at a given position.
+type Diagnostic struct {
+	File    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (d Diagnostic) String() string {
+	if d.Line == 0 {
+		return fmt.Sprintf("%s: %s", d.File, d.Message)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", d.File, d.Line, d.Column, d.Message)
+}
+
+// ConfigError is returned when loading a config in strict mode finds problems.
+type ConfigError struct {
+	Diagnostics []Diagnostic
+}
+
+func (e *ConfigError) Error() string {
+	lines := make([]string, len(e.Diagnostics))
+	for i, d := range e.Diagnostics {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	errorLinePattern = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	hexColorPattern  = regexp.MustCompile(`^#([0-9a-fA-F]{3}|[0-9a-fA-F]{6})$`)
+)
+
+// Validate checks the project and user config, returning every problem found.
+func Validate() ([]Diagnostic, error) {
+	_, _, diagnostics, err := readConfig(Options{})
+	var configErr *ConfigError
+	if errors.As(err, &configErr) {
+		diagnostics, err = configErr.Diagnostics, nil
+	}
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].File != diagnostics[j].File {
+			return diagnostics[i].File < diagnostics[j].File
+		}
+		return diagnostics[i].Line < diagnostics[j].Line
+	})
+	return diagnostics, err
+}
+
+// parseConfigFile decodes the file at path into out, reporting type errors
+// and unknown fields as diagnostics. Syntax errors are returned as a ConfigError.
+func parseConfigFile(path string, out any) (*yaml.Node, []Diagnostic, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	file := filepath.Clean(path)
+
+	root, err := parseNode(file, data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(root.Content) == 0 {
+		return root, nil, nil
+	}
+
+	var diagnostics []Diagnostic
+	if err := root.Decode(out); err != nil {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return nil, nil, &ConfigError{[]Diagnostic{errorDiagnostic(file, err.Error())}}
+		}
+		for _, e := range typeErr.Errors {
+			diagnostics = append(diagnostics, errorDiagnostic(file, e))
+		}
+	}
+
+	diagnostics = append(diagnostics, checkFields(file, root.Content[0], reflect.TypeOf(out).Elem())...)
+	return root, diagnostics, nil
+}
+
+func errorDiagnostic(file, msg string) Diagnostic {
+	if m := errorLinePattern.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return Diagnostic{File: file, Line: line, Column: 1, Message: m[2]}
+	}
+	return Diagnostic{File: file, Message: strings.TrimPrefix(msg, "yaml: ")}
+}
+
+func nodeDiagnostic(file string, node *yaml.Node, format string, args ...any) Diagnostic {
+	d := Diagnostic{File: filepath.Clean(file), Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		d.Line, d.Column = node.Line, node.Column
+	}
+	return d
+}
+
+// yamlFields maps the yaml keys of a struct type to their fields.
+func yamlFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name, opts, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+		if name == "-" {
+			continue
+		}
+		if strings.Contains(opts, "inline") {
+			for k, v := range yamlFields(f.Type) {
+				fields[k] = v
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		fields[name] = f
+	}
+	return fields
+}
+
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// checkFields walks node alongside t and reports keys that don't map to a field.
+func checkFields(file string, node *yaml.Node, t reflect.Type) []Diagnostic {
+	node = resolveAlias(node)
+	if node == nil {
+		return nil
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var diagnostics []Diagnostic
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.Value == "<<" {
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				diagnostics = append(diagnostics, unknownField(file, key, fields))
+				continue
+			}
+			diagnostics = append(diagnostics, checkFields(file, value, field.Type)...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for _, child := range node.Content {
+			diagnostics = append(diagnostics, checkFields(file, child, t.Elem())...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 1; i < len(node.Content); i += 2 {
+			diagnostics = append(diagnostics, checkFields(file, node.Content[i], t.Elem())...)
+		}
+	}
+	return diagnostics
+}
+
+func unknownField(file string, key *yaml.Node, fields map[string]reflect.StructField) Diagnostic {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestDistance := "", 3
+	for _, name := range names {
+		if d := editDistance(key.Value, name); d < bestDistance {
+			best, bestDistance = name, d
+		}
+	}
+	if best != "" {
+		return nodeDiagnostic(file, key, "unknown field %q, did you mean %q?", key.Value, best)
+	}
+	return nodeDiagnostic(file, key, "unknown field %q (expected one of %s)", key.Value, strings.Join(names, ", "))
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// mappingValue returns the value for key in a mapping node, following merge keys.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	node = resolveAlias(node)
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	var merged *yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		switch node.Content[i].Value {
+		case key:
+			return resolveAlias(node.Content[i+1])
+		case "<<":
+			merged = node.Content[i+1]
+		}
+	}
+	if merged != nil {
+		return mappingValue(merged, key)
+	}
+	return nil
+}
+
+// validateCommand checks an expanded command against the node it came from in its config file.
+func validateCommand(entry configEntry) []Diagnostic {
+	cmd, node, file := entry.cmd, entry.node, entry.file
+	defaults := mappingValue(entry.doc, "defaults")
+
+	var diagnostics []Diagnostic
+	value := reflect.ValueOf(cmd)
+	for name, field := range yamlFields(value.Type()) {
+		if field.Tag.Get("validate") != "required" {
+			continue
+		}
+		if value.FieldByIndex(field.Index).IsZero() {
+			diagnostics = append(diagnostics, nodeDiagnostic(file, node, "%s: missing required field %q", entry.label, name))
+		}
+	}
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].Message < diagnostics[j].Message })
+
+	for _, pattern := range cmd.IgnorePatterns {
+		if _, err := glob.Compile(pattern, '/'); err != nil {
+			diagnostics = append(diagnostics, nodeDiagnostic(file, node, "%s: invalid ignore pattern %q: %v", entry.label, pattern, err))
+		}
+	}
+
+	if _, err := compileMatchers(cmd.Matchers); err != nil {
+		diagnostics = append(diagnostics, nodeDiagnostic(file, node, "%s: %v", entry.label, err))
+	}
+	diagnostics = append(diagnostics, validateNotify(entry)...)
+	diagnostics = append(diagnostics, validateHooks(file, node, entry.label, cmd.Hooks)...)
+
+	// point at the list the watch paths came from, whether the command or defaults
+	paths := mappingValue(node, "watch_paths")
+	if paths == nil {
+		paths = mappingValue(defaults, "watch_paths")
+	}
+	for i, path := range cmd.WatchPaths {
+		if _, err := os.Stat(path); err == nil {
+			continue
+		}
+		at := node
+		if paths != nil && paths.Kind == yaml.SequenceNode && i < len(paths.Content) {
+			at = paths.Content[i]
+		}
+		diagnostics = append(diagnostics, nodeDiagnostic(file, at, "%s: watch path %q does not exist", entry.label, path))
+	}
+
+	return diagnostics
+}
+
+// documentNode returns the top-level node of a parsed file, if any.
+func documentNode(root *yaml.Node) *yaml.Node {
+	if root == nil || len(root.Content) == 0 {
+		return nil
+	}
+	return root.Content[0]
+}
+
+// sequenceItem returns the i'th item of the sequence under key in node.
+func sequenceItem(node *yaml.Node, key string, i int) *yaml.Node {
+	seq := mappingValue(node, key)
+	if seq == nil || seq.Kind != yaml.SequenceNode || i >= len(seq.Content) {
+		return nil
+	}
+	return resolveAlias(seq.Content[i])
+}
+
+func validateProjectTheme(file string, root *yaml.Node, preset string) []Diagnostic {
+	if preset == "" || preset == "default" {
+		return nil
+	}
+	if _, ok := themePresets[preset]; ok {
+		return nil
+	}
+	node := mappingValue(documentNode(root), "theme")
+	return []Diagnostic{nodeDiagnostic(file, node, "unknown theme %q (expected one of %s)", preset, presetNames())}
+}
+
+func validateUserConfig(file string, root *yaml.Node, conf Config) []Diagnostic {
+	doc := documentNode(root)
+
+	var diagnostics []Diagnostic
+	if conf.ThemePreset != "" && conf.ThemePreset != "default" {
+		if _, ok := themePresets[conf.ThemePreset]; !ok {
+			diagnostics = append(diagnostics, nodeDiagnostic(file, mappingValue(doc, "theme_preset"),
+				"unknown theme preset %q (expected one of %s)", conf.ThemePreset, presetNames()))
+		}
+	}
+
+	theme := mappingValue(doc, "theme")
+	value := reflect.ValueOf(conf.ThemeConfig)
+	for name, field := range yamlFields(value.Type()) {
+		color := value.FieldByIndex(field.Index).String()
+		if color == "" || validColor(color) {
+			continue
+		}
+		diagnostics = append(diagnostics, nodeDiagnostic(file, mappingValue(theme, name),
+			"invalid color %q for theme.%s (expected #rgb, #rrggbb or an ANSI color number)", color, name))
+	}
+	sort.Slice(diagnostics, func(i, j int) bool { return diagnostics[i].Line < diagnostics[j].Line })
+
+	return diagnostics
+}
+
+func validColor(color string) bool {
+	if hexColorPattern.MatchString(color) {
+		return true
+	}
+	n, err := strconv.Atoi(color)
+	return err == nil && n >= 0 && n <= 255
+}
+
+func presetNames() string {
+	names := make([]string, 0, len(themePresets))
+	for name := range themePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
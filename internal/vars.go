@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// matches ${name}, ${env:NAME} and the escaped form $${...}
+var varPattern = regexp.MustCompile(`\$?\$\{([^}]+)\}`)
+
+const envPrefix = "env:"
+
+// resolveVars expands references between vars so each value can be
+// substituted directly. Vars may reference the environment and each other.
+func resolveVars(vars map[string]string) (map[string]string, error) {
+	resolved := make(map[string]string, len(vars))
+
+	var resolve func(name string, seen []string) (string, error)
+	resolve = func(name string, seen []string) (string, error) {
+		if value, ok := resolved[name]; ok {
+			return value, nil
+		}
+		for _, s := range seen {
+			if s == name {
+				return "", fmt.Errorf("cycle in vars: %s -> %s", strings.Join(seen, " -> "), name)
+			}
+		}
+
+		var err error
+		value := varPattern.ReplaceAllStringFunc(vars[name], func(match string) string {
+			if err != nil {
+				return match
+			}
+			if strings.HasPrefix(match, "$$") {
+				return match[1:]
+			}
+			ref := varPattern.FindStringSubmatch(match)[1]
+			if strings.HasPrefix(ref, envPrefix) {
+				return os.Getenv(strings.TrimPrefix(ref, envPrefix))
+			}
+			if _, ok := vars[ref]; !ok {
+				return match
+			}
+			var v string
+			v, err = resolve(ref, append(seen, name))
+			return v
+		})
+		if err != nil {
+			return "", err
+		}
+
+		resolved[name] = value
+		return value, nil
+	}
+
+	for name := range vars {
+		if _, err := resolve(name, nil); err != nil {
+			return nil, err
+		}
+	}
+
+	return resolved, nil
+}
+
+// interpolate substitutes ${var} and ${env:NAME} references in s.
+// References to unknown vars are left as-is so shell parameter expansion
+// like ${HOME} in a cmd keeps working, and $${...} escapes a reference.
+func interpolate(s string, vars map[string]string) string {
+	return varPattern.ReplaceAllStringFunc(s, func(match string) string {
+		if strings.HasPrefix(match, "$$") {
+			return match[1:]
+		}
+		ref := varPattern.FindStringSubmatch(match)[1]
+		if strings.HasPrefix(ref, envPrefix) {
+			return os.Getenv(strings.TrimPrefix(ref, envPrefix))
+		}
+		if value, ok := vars[ref]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+func interpolateAll(values []string, vars map[string]string) []string {
+	if values == nil {
+		return nil
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		out[i] = interpolate(v, vars)
+	}
+	return out
+}
+
+// applyDefaults fills in any field the command leaves unset from defaults.
+// Env maps are merged, with the command's own keys taking precedence.
+func applyDefaults(cmd Command, defaults Command) Command {
+	if cmd.Cmd == "" {
+		cmd.Cmd = defaults.Cmd
+	}
+	if len(cmd.WatchPaths) == 0 {
+		cmd.WatchPaths = defaults.WatchPaths
+	}
+	if len(cmd.IgnorePaths) == 0 {
+		cmd.IgnorePaths = defaults.IgnorePaths
+	}
+	if len(cmd.IgnorePatterns) == 0 {
+		cmd.IgnorePatterns = defaults.IgnorePatterns
+	}
+	if len(cmd.Matchers) == 0 {
+		cmd.Matchers = defaults.Matchers
+	}
+	if len(cmd.NotifyOn) == 0 {
+		cmd.NotifyOn = defaults.NotifyOn
+	}
+	if len(cmd.NotifyWith) == 0 {
+		cmd.NotifyWith = defaults.NotifyWith
+	}
+	if cmd.NotifyCmd == "" {
+		cmd.NotifyCmd = defaults.NotifyCmd
+	}
+	if cmd.NotifyInterval == 0 {
+		cmd.NotifyInterval = defaults.NotifyInterval
+	}
+	if len(cmd.Before) == 0 {
+		cmd.Before = defaults.Before
+	}
+	if len(cmd.After) == 0 {
+		cmd.After = defaults.After
+		cmd.AlwaysAfter = cmd.AlwaysAfter || defaults.AlwaysAfter
+	}
+	if cmd.Shell == "" {
+		cmd.Shell = defaults.Shell
+	}
+	if cmd.Debounce == 0 {
+		cmd.Debounce = defaults.Debounce
+	}
+	if len(defaults.Env) > 0 {
+		env := make(map[string]string, len(defaults.Env)+len(cmd.Env))
+		for k, v := range defaults.Env {
+			env[k] = v
+		}
+		for k, v := range cmd.Env {
+			env[k] = v
+		}
+		cmd.Env = env
+	}
+	return cmd
+}
+
+// expandCommand applies defaults and vars to a command as written in the config.
+func expandCommand(cmd Command, defaults Command, vars map[string]string) Command {
+	cmd = applyDefaults(cmd, defaults)
+	cmd.Cmd = interpolate(cmd.Cmd, vars)
+	cmd.Before = interpolateAll(cmd.Before, vars)
+	cmd.After = interpolateAll(cmd.After, vars)
+	cmd.WatchPaths = interpolateAll(cmd.WatchPaths, vars)
+	cmd.IgnorePaths = interpolateAll(cmd.IgnorePaths, vars)
+	cmd.Hooks = cmd.Hooks.interpolate(vars)
+	if cmd.Env != nil {
+		env := make(map[string]string, len(cmd.Env))
+		for k, v := range cmd.Env {
+			env[k] = interpolate(v, vars)
+		}
+		cmd.Env = env
+	}
+	return cmd
+}
@@ -7,12 +7,18 @@ import (
 	"io"
 	"log"
 	"os"
+	"os/signal"
+	"runtime"
 	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
 
 	panopticon "github.com/cfbender/panopticon/internal"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/gobwas/glob"
+	"golang.org/x/term"
 )
 
 func main() {
@@ -21,8 +27,20 @@ func main() {
 		showHelp    bool
 		showVersion bool
 		verbose     bool
+		strict      bool
+		shell       string
+		debounce    time.Duration
 		match       string
 		theme       string
+		layout      string
+		noTUI       bool
+		events      string
+		eventsFile  string
+		junit       string
+		markdown    string
+		listen      string
+		api         bool
+		allowRemote bool
 		opts        []tea.ProgramOption
 	)
 
@@ -41,6 +59,26 @@ func main() {
 
 	flag.StringVar(&theme, "theme", "", "theme preset to use")
 
+	flag.StringVar(&layout, "layout", "", "where to show output: inline, split or stacked")
+
+	flag.BoolVar(&noTUI, "no-tui", false, "print prefixed output lines instead of starting the TUI, the default when stdout isn't a terminal")
+
+	flag.StringVar(&events, "events", "", "emit newline-delimited events in this format (json) to stdout or --events-file")
+	flag.StringVar(&eventsFile, "events-file", "", "file or FIFO to write events to instead of stdout")
+
+	flag.StringVar(&junit, "junit", "", "write a JUnit XML report of the latest runs to this path, overriding the config")
+	flag.StringVar(&markdown, "markdown", "", "write a Markdown summary of the latest runs to this path, overriding the config")
+
+	flag.StringVar(&listen, "listen", "", "serve the control API on a Unix socket path or localhost:port")
+	flag.BoolVar(&api, "api", false, "serve the control API on "+panopticon.SocketPath()+", for the trigger, status and logs subcommands")
+	flag.BoolVar(&allowRemote, "allow-remote", false, "let --listen bind a non-loopback address, requiring the token written to "+panopticon.TokenPath())
+
+	flag.BoolVar(&strict, "strict", false, "fail on unknown fields, missing fields and invalid values in config")
+
+	flag.StringVar(&shell, "shell", "", "shell to run commands with, overriding the config")
+
+	flag.DurationVar(&debounce, "debounce", 0, "how long to wait for changes to settle before running, overriding the config")
+
 	flag.Parse()
 
 	if showHelp {
@@ -54,21 +92,13 @@ func main() {
 		os.Exit(0)
 	}
 
-	argsWithoutProg := os.Args[1:]
+	args := flag.Args()
 
-	if len(argsWithoutProg) > 0 {
-		if argsWithoutProg[0] == "init" {
-			panopticon.InitConfig()
-			os.Exit(0)
-		}
+	// init prints its progress through log, so it runs before logging is set up
+	if len(args) > 0 && args[0] == "init" {
+		os.Exit(initConfig(args[1:]))
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	g := glob.MustCompile(match)
-	model := panopticon.NewModel(cancel, g, theme)
-
 	if !verbose {
 		log.SetOutput(io.Discard)
 	} else {
@@ -83,18 +113,361 @@ func main() {
 		log.SetOutput(f)
 	}
 
-	opts = append(opts, tea.WithAltScreen())
-	p := tea.NewProgram(model, opts...)
+	if events != "" && events != "json" {
+		fmt.Printf("Unknown events format %q (expected json)\n", events)
+		os.Exit(1)
+	}
+	if len(args) > 0 {
+		switch args[0] {
+		case "validate":
+			os.Exit(validate())
+		case "import":
+			os.Exit(importCommands(args[1:]))
+		case "schema":
+			os.Exit(schema(args[1:]))
+		case "trigger":
+			os.Exit(triggerCommands(args[1:]))
+		case "status":
+			os.Exit(status(args[1:]))
+		case "logs":
+			os.Exit(logs(args[1:]))
+		case "run":
+			if events != "" && eventsFile == "" {
+				fmt.Println("Use --events-file to emit events from run, which prints to stdout")
+				os.Exit(1)
+			}
+			eventsOut, closeEvents := openEvents(events, eventsFile)
+			code := runOnce(args[1:], match, eventsOut, panopticon.Options{
+				Theme:    theme,
+				Strict:   strict,
+				Shell:    shell,
+				Debounce: debounce,
+				JUnit:    junit,
+				Markdown: markdown,
+			})
+			closeEvents()
+			os.Exit(code)
+		}
+	}
+
+	eventsOut, closeEvents := openEvents(events, eventsFile)
+	defer closeEvents()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	g := glob.MustCompile(match)
+	model := panopticon.NewModel(cancel, g, panopticon.Options{
+		Theme:    theme,
+		Strict:   strict,
+		Shell:    shell,
+		Debounce: debounce,
+		Layout:   layout,
+		JUnit:    junit,
+		Markdown: markdown,
+	})
+
+	// events on stdout replace both the TUI and the printed output
+	headless := eventsOut == os.Stdout || noTUI || !term.IsTerminal(int(os.Stdout.Fd()))
+
+	var p *tea.Program
+	var senders []panopticon.Sender
+	if !headless {
+		opts = append(opts, tea.WithAltScreen())
+		p = tea.NewProgram(model, opts...)
+		senders = append(senders, p)
+		panopticon.NotifyThrough(model, p)
+	}
+	if eventsOut != nil {
+		senders = append(senders, panopticon.NewEventStream(model, eventsOut))
+	}
+	if headless && eventsOut != os.Stdout {
+		senders = append(senders, panopticon.NewHeadless(model, os.Stdout))
+	}
+
+	if api && listen == "" {
+		listen = panopticon.SocketPath()
+	}
+	if listen != "" {
+		l, err := panopticon.Listen(listen, allowRemote)
+		if err != nil {
+			fmt.Println("Error starting API:", err)
+			os.Exit(1)
+		}
+		defer l.Close()
+
+		var notify panopticon.Sender
+		if p != nil {
+			notify = p
+		}
+		srv := panopticon.NewServer(model, notify)
+		if allowRemote {
+			token, err := panopticon.NewToken(panopticon.TokenPath())
+			if err != nil {
+				fmt.Println("Error writing API token:", err)
+				os.Exit(1)
+			}
+			srv.RequireToken(token)
+		}
+		senders = append(senders, srv)
+		go panopticon.Serve(ctx, l, srv)
+	}
+
+	s := panopticon.NewBroadcast(model, senders...)
+	if headless {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		panopticon.RunHeadless(model, s, ctx, runOnStart)
+		return
+	}
 
 	if runOnStart {
-		go panopticon.RunAll(model, p, ctx)
+		go panopticon.RunAll(model, s, ctx)
 	}
 
-	go panopticon.WatchForChanges(model, p, ctx)
-	go panopticon.WatchForTriggers(model, p, ctx)
+	go panopticon.WatchForChanges(model, s, ctx)
+	go panopticon.WatchForTriggers(model, s, ctx)
 
 	if _, err := p.Run(); err != nil {
 		fmt.Println("Error starting Bubble Tea program:", err)
 		os.Exit(1)
 	}
 }
+
+// openEvents returns where to write events in format, if any: stdout, or
+// file, which is truncated. It exits if file can't be opened.
+func openEvents(format, file string) (io.Writer, func()) {
+	if format == "" {
+		return nil, func() {}
+	}
+	if file == "" {
+		return os.Stdout, func() {}
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		fmt.Println("Error opening events file:", err)
+		os.Exit(1)
+	}
+	return f, func() { f.Close() }
+}
+
+// anyGlob matches if any of its patterns do.
+type anyGlob []glob.Glob
+
+func (a anyGlob) Match(s string) bool {
+	for _, g := range a {
+		if g.Match(s) {
+			return true
+		}
+	}
+	return false
+}
+
+func runOnce(args []string, match string, eventsOut io.Writer, opts panopticon.Options) int {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	var jobs int
+	fs.IntVar(&jobs, "jobs", runtime.NumCPU(), "how many commands to run at once")
+	fs.IntVar(&jobs, "j", runtime.NumCPU(), "how many commands to run at once")
+	fs.StringVar(&opts.JUnit, "junit", opts.JUnit, "write a JUnit XML report to this path, overriding the config")
+	fs.StringVar(&opts.Markdown, "markdown", opts.Markdown, "write a Markdown summary to this path, overriding the config")
+	fs.Parse(args)
+
+	// commands named on the command line, or all those matching --match
+	patterns := fs.Args()
+	if len(patterns) == 0 {
+		patterns = []string{match}
+	}
+	var g anyGlob
+	for _, pattern := range patterns {
+		compiled, err := glob.Compile(pattern)
+		if err != nil {
+			fmt.Printf("Invalid pattern %q: %v\n", pattern, err)
+			return 1
+		}
+		g = append(g, compiled)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	model := panopticon.NewModel(cancel, g, opts)
+	var extra []panopticon.Sender
+	if eventsOut != nil {
+		extra = append(extra, panopticon.NewEventStream(model, eventsOut))
+	}
+	return panopticon.RunOnce(model, os.Stdout, ctx, jobs, extra...)
+}
+
+// clientFlags adds the --addr flag for connecting to a running panopticon.
+func clientFlags(name, usage string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	addr := fs.String("addr", panopticon.SocketPath(), "Unix socket path or localhost:port of the running panopticon's API")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: panopticon %s\n", usage)
+		fs.PrintDefaults()
+	}
+	return fs, addr
+}
+
+func triggerCommands(args []string) int {
+	fs, addr := clientFlags("trigger", "trigger [--addr ADDR] <name|glob>...")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+
+	client, err := panopticon.NewClient(*addr)
+	if err == nil {
+		err = panopticon.Trigger(context.Background(), client, os.Stdout, fs.Args())
+	}
+	if err != nil {
+		fmt.Println("Error triggering commands:", err)
+		return 1
+	}
+	return 0
+}
+
+func status(args []string) int {
+	fs, addr := clientFlags("status", "status [--addr ADDR]")
+	fs.Parse(args)
+
+	client, err := panopticon.NewClient(*addr)
+	if err == nil {
+		err = panopticon.PrintStatus(context.Background(), client, os.Stdout)
+	}
+	if err != nil {
+		fmt.Println("Error getting status:", err)
+		return 1
+	}
+	return 0
+}
+
+func logs(args []string) int {
+	fs, addr := clientFlags("logs", "logs [--addr ADDR] [-f] <name>")
+	var follow bool
+	fs.BoolVar(&follow, "follow", false, "keep printing output as it arrives")
+	fs.BoolVar(&follow, "f", false, "keep printing output as it arrives")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 1
+	}
+	// allow flags after the name, as in logs test -f
+	name := fs.Arg(0)
+	fs.Parse(fs.Args()[1:])
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	client, err := panopticon.NewClient(*addr)
+	if err == nil {
+		err = panopticon.Logs(ctx, client, os.Stdout, name, follow)
+	}
+	if err != nil {
+		fmt.Println("Error getting logs:", err)
+		return 1
+	}
+	return 0
+}
+
+func validate() int {
+	log.SetOutput(io.Discard)
+
+	diagnostics, err := panopticon.Validate()
+	if err != nil {
+		fmt.Println("Error loading config:", err)
+		return 1
+	}
+
+	for _, d := range diagnostics {
+		fmt.Println(d)
+	}
+	if len(diagnostics) > 0 {
+		fmt.Printf("%d problem(s) found\n", len(diagnostics))
+		return 1
+	}
+
+	fmt.Println("Config is valid")
+	return 0
+}
+
+func schema(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ExitOnError)
+	write := fs.Bool("w", false, "write schemas to their files in the current directory instead of stdout")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: panopticon schema [-w] [%s]\n", strings.Join(panopticon.SchemaNames(), "|"))
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	names := fs.Args()
+	if len(names) == 0 {
+		if !*write {
+			names = []string{"project"}
+		} else {
+			names = panopticon.SchemaNames()
+		}
+	}
+
+	for _, name := range names {
+		out, err := panopticon.Schema(name)
+		if err != nil {
+			fmt.Println("Error generating schema:", err)
+			return 1
+		}
+
+		if !*write {
+			fmt.Print(string(out))
+			continue
+		}
+
+		file := panopticon.SchemaFile(name)
+		if err := os.WriteFile(file, out, 0o644); err != nil {
+			fmt.Println("Error writing schema:", err)
+			return 1
+		}
+		fmt.Println("Wrote", file)
+	}
+
+	return 0
+}
+
+func initConfig(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ExitOnError)
+	var yes bool
+	fs.BoolVar(&yes, "yes", false, "include the suggested commands without prompting")
+	fs.BoolVar(&yes, "y", false, "include the suggested commands without prompting")
+	fs.Parse(args)
+
+	if err := panopticon.InitConfig(yes); err != nil {
+		fmt.Println("Error creating config:", err)
+		return 1
+	}
+	return 0
+}
+
+func importCommands(files []string) int {
+	if len(files) == 0 {
+		fmt.Println("Usage: panopticon import <Procfile|Makefile|justfile|package.json>...")
+		return 1
+	}
+
+	added, err := panopticon.AddImports(files)
+	if err != nil {
+		fmt.Println("Error importing commands:", err)
+		return 1
+	}
+
+	if len(added.Skipped) > 0 {
+		fmt.Printf("Skipped %s, already imported by panopticon.yaml\n", strings.Join(added.Skipped, ", "))
+	}
+	if len(added.Added) == 0 {
+		return 0
+	}
+	fmt.Printf("Added %s to panopticon.yaml, importing %d command(s):\n", strings.Join(added.Added, ", "), len(added.Commands))
+	for _, cmd := range added.Commands {
+		fmt.Printf("  %s: %s\n", cmd.Name, cmd.Cmd)
+	}
+	return 0
+}